@@ -0,0 +1,81 @@
+package configs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// Config : configuration of the Lambda
+type Config struct {
+	Envs EnvParameters
+	// File : configuration file, zero value unless CONFIG_SOURCE is set
+	File FileConfig
+	// SecretProvider : provider of the secrets, which are resolved by ResolveSecrets only when they are needed
+	SecretProvider SecretProvider
+	Session        *session.Session
+}
+
+// Load ... load the configuration from the environment values and the configuration file
+//
+// Nothing is accessed until Load is called, so that importing the package has no side effects.
+// The secrets are not resolved by Load, so that a dry run does not need them.
+func Load(ctx context.Context) (*Config, error) {
+	e, err := LoadEnvs()
+	if err != nil {
+		return nil, err
+	}
+
+	sess, err := session.NewSession(&aws.Config{
+		Region: aws.String(e.AWSRegionID),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed on session.NewSession")
+	}
+
+	return load(e, sess, awsapi.NewS3(s3.New(sess)), awsapi.NewSSMClient(ssm.New(sess)), newSecretProvider)
+}
+
+func load(e EnvParameters, sess *session.Session, s3Client awsapi.S3Iface, ssmClient awsapi.SSMIface, newProvider func(EnvParameters, *session.Session) (SecretProvider, error)) (*Config, error) {
+	c := &Config{
+		Envs:    e,
+		Session: sess,
+	}
+
+	if e.ConfigSource != "" {
+		data, err := readConfigSource(e.ConfigSource, s3Client, ssmClient)
+		if err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("failed to read the config %s", e.ConfigSource))
+		}
+		if c.File, err = parseFileConfig(data); err != nil {
+			return nil, errors.Wrap(err, fmt.Sprintf("invalid config %s", e.ConfigSource))
+		}
+		c.Envs = c.File.apply(c.Envs)
+	}
+
+	provider, err := newProvider(c.Envs, sess)
+	if err != nil {
+		return nil, err
+	}
+	c.SecretProvider = provider
+	return c, nil
+}
+
+// ResolveSecrets ... secrets required by the sinks, resolved by SecretProvider on every call
+func (c *Config) ResolveSecrets(ctx context.Context) (SecretParameters, error) {
+	if c.SecretProvider == nil {
+		return SecretParameters{}, fmt.Errorf("no secret provider")
+	}
+	s, err := resolveSecrets(ctx, c.Envs, c.SecretProvider)
+	if err != nil {
+		return SecretParameters{}, errors.Wrap(err, "failed to resolve the secrets")
+	}
+	return s, nil
+}
@@ -0,0 +1,194 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
+)
+
+type mockSecretProvider struct {
+	Values SecretParameters
+	Error  error
+	// calls : number of the calls of Secrets
+	calls int
+}
+
+func (m *mockSecretProvider) Secrets(ctx context.Context) (SecretParameters, error) {
+	m.calls++
+	return m.Values, m.Error
+}
+
+func TestLoadEnvs(t *testing.T) {
+	e, err := LoadEnvs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff([]string{"datadog"}, e.Sinks); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if diff := cmp.Diff("ssm", e.SecretProvider); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+
+	for _, kv := range [][]string{
+		{"LOOKBACK_DAYS", "0"},
+		{"SECRET_PROVIDER", "vault"},
+		{"MAX_DATA_LAG_DAYS", "-1"},
+		{"CONCURRENCY", "0"},
+		{"COST_EXPLORER_MAX_RETRIES", "-1"},
+		{"DEADLINE_MARGIN", "-1s"},
+		{"CONFIG_TTL", "-1m"},
+		{"CACHE_FINALIZED_DAYS", "0"},
+		{"DRY_RUN_FORMAT", "yaml"},
+	} {
+		os.Setenv(kv[0], kv[1])
+		if _, err := LoadEnvs(); err == nil {
+			t.Errorf("wrong result : err is nil with %s=%s", kv[0], kv[1])
+		}
+		os.Unsetenv(kv[0])
+	}
+}
+
+func TestLoad(t *testing.T) {
+	e, err := LoadEnvs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	provider := &mockSecretProvider{Error: errors.New("AccessDenied")}
+
+	tests := []struct {
+		name     string
+		envs     func(EnvParameters) EnvParameters
+		expected *Config
+		wantErr  bool
+	}{
+		{
+			// secret は必要になるまで取得しない
+			name: "secrets are not resolved",
+			envs: func(e EnvParameters) EnvParameters { return e },
+			expected: &Config{
+				Envs: e,
+			},
+		},
+		{
+			name: "config file",
+			envs: func(e EnvParameters) EnvParameters {
+				e.ConfigSource = "s3://bucket/config.yaml"
+				return e
+			},
+			expected: &Config{
+				Envs: func(e EnvParameters) EnvParameters {
+					e.ConfigSource = "s3://bucket/config.yaml"
+					e.Sinks = []string{"cloudwatch"}
+					return e
+				}(e),
+				File: FileConfig{Sinks: []string{"cloudwatch"}},
+			},
+		},
+		{
+			name: "invalid config file",
+			envs: func(e EnvParameters) EnvParameters {
+				e.ConfigSource = "s3://bucket/invalid.yaml"
+				return e
+			},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s3Client := &mockS3{
+				Objects: map[string]string{
+					"bucket/config.yaml":  "sinks: [cloudwatch]",
+					"bucket/invalid.yaml": "sinks: [stdout]",
+				},
+			}
+			newProvider := func(EnvParameters, *session.Session) (SecretProvider, error) {
+				return provider, nil
+			}
+			c, err := load(tt.envs(e), nil, s3Client, &mockSSM{}, newProvider)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("load() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, c, cmpopts.EquateEmpty(), cmpopts.IgnoreFields(Config{}, "SecretProvider")); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+			if c.SecretProvider != provider {
+				t.Errorf("wrong result : %v", c.SecretProvider)
+			}
+			if provider.calls != 0 {
+				t.Errorf("wrong result : secrets are provided %d times", provider.calls)
+			}
+		})
+	}
+}
+
+func TestResolveSecrets(t *testing.T) {
+	e, err := LoadEnvs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"}
+
+	tests := []struct {
+		name     string
+		sinks    []string
+		provider *mockSecretProvider
+		expected SecretParameters
+		// calls : number of the calls of the secret provider
+		calls   int
+		wantErr bool
+	}{
+		{
+			name:     "datadog secrets",
+			sinks:    []string{"datadog"},
+			provider: &mockSecretProvider{Values: secrets},
+			expected: secrets,
+			calls:    1,
+		},
+		{
+			// datadog に送信しなければ secret は不要
+			name:     "no secret without datadog sink",
+			sinks:    []string{"cloudwatch"},
+			provider: &mockSecretProvider{Error: errors.New("AccessDenied")},
+		},
+		{
+			name:     "empty secret",
+			sinks:    []string{"datadog"},
+			provider: &mockSecretProvider{Values: SecretParameters{DatadogAPIKey: "hogehoge"}},
+			calls:    1,
+			wantErr:  true,
+		},
+		{
+			name:     "secret provider failed",
+			sinks:    []string{"datadog"},
+			provider: &mockSecretProvider{Error: errors.New("AccessDenied")},
+			calls:    1,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			envs := e
+			envs.Sinks = tt.sinks
+			c := &Config{Envs: envs, SecretProvider: tt.provider}
+			s, err := c.ResolveSecrets(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ResolveSecrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, s); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+			if tt.provider.calls != tt.calls {
+				t.Errorf("wrong result : secrets are provided %d times", tt.provider.calls)
+			}
+		})
+	}
+}
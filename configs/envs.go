@@ -1,61 +1,97 @@
 package configs
 
 import (
-	"log"
+	"fmt"
+	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/caarlos0/env"
-	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
 	"github.com/pkg/errors"
 )
 
-// Envs : environment values
-var Envs envParameters
-
-type envParameters struct {
-	DatadogAPIKeyName string `env:"DD_API_KEY_NAME" envDefault:"datadog_api_key"`
-	DatadogAppKeyName string `env:"DD_APP_KEY_NAME" envDefault:"datadog_app_key"`
-	TagKey            string `env:"TAG_KEY" envDefault:"account"`
-	TagVal            string `env:"TAG_VAL" envDefault:"yourproject"`
-	AWSRegionID       string `env:"AWS_REGION"`
+// EnvParameters : environment values
+type EnvParameters struct {
+	DatadogAPIKeyName      string        `env:"DD_API_KEY_NAME" envDefault:"datadog_api_key"`
+	DatadogAppKeyName      string        `env:"DD_APP_KEY_NAME" envDefault:"datadog_app_key"`
+	SSMPath                string        `env:"SSM_PATH"`
+	DatadogSecretID        string        `env:"DD_SECRET_ID" envDefault:"datadog"`
+	SecretProvider         string        `env:"SECRET_PROVIDER" envDefault:"ssm"`
+	TagKey                 string        `env:"TAG_KEY" envDefault:"account"`
+	TagVal                 string        `env:"TAG_VAL" envDefault:"yourproject"`
+	Sinks                  []string      `env:"SINKS" envDefault:"datadog" envSeparator:","`
+	CloudWatchNamespace    string        `env:"CW_NAMESPACE" envDefault:"RIUtilizationPlotter"`
+	SavingsPlans           bool          `env:"SAVINGS_PLANS" envDefault:"false"`
+	RISubscriptions        bool          `env:"RI_SUBSCRIPTIONS" envDefault:"false"`
+	LookbackDays           int           `env:"LOOKBACK_DAYS" envDefault:"2"`
+	MaxDataLagDays         int           `env:"MAX_DATA_LAG_DAYS" envDefault:"3"`
+	Granularity            string        `env:"GRANULARITY" envDefault:"DAILY"`
+	DiscoverServices       bool          `env:"DISCOVER_SERVICES" envDefault:"true"`
+	ServicesAllow          []string      `env:"SERVICES_ALLOW" envSeparator:","`
+	ServicesDeny           []string      `env:"SERVICES_DENY" envSeparator:","`
+	LinkedAccounts         bool          `env:"LINKED_ACCOUNTS" envDefault:"false"`
+	ResolveAccountNames    bool          `env:"RESOLVE_ACCOUNT_NAMES" envDefault:"false"`
+	Accounts               []string      `env:"ACCOUNTS" envSeparator:","`
+	AssumeRoleName         string        `env:"ASSUME_ROLE_NAME" envDefault:"ri-utilization-plotter"`
+	ConfigSource           string        `env:"CONFIG_SOURCE"`
+	Concurrency            int           `env:"CONCURRENCY" envDefault:"4"`
+	CostExplorerRPS        float64       `env:"COST_EXPLORER_RPS" envDefault:"5"`
+	CostExplorerMaxRetries int           `env:"COST_EXPLORER_MAX_RETRIES" envDefault:"5"`
+	DeadlineMargin         time.Duration `env:"DEADLINE_MARGIN" envDefault:"30s"`
+	ConfigTTL              time.Duration `env:"CONFIG_TTL" envDefault:"1h"`
+	CacheStore             string        `env:"CACHE_STORE"`
+	CacheFinalizedDays     int           `env:"CACHE_FINALIZED_DAYS" envDefault:"3"`
+	DryRun                 bool          `env:"DRY_RUN" envDefault:"false"`
+	DryRunFormat           string        `env:"DRY_RUN_FORMAT" envDefault:"table"`
+	AWSRegionID            string        `env:"AWS_REGION"`
 }
 
-// Session : session
-var Session *session.Session
-
-// Secrets : secrets
-var Secrets SecretParameters
-
-// SecretParameters : secret parameters
-type SecretParameters struct {
-	DatadogAPIKey string
-	DatadogAppKey string
+func (e EnvParameters) validate() error {
+	if e.LookbackDays < 1 {
+		return fmt.Errorf("LOOKBACK_DAYS must be positive: %d", e.LookbackDays)
+	}
+	if e.MaxDataLagDays < 0 {
+		return fmt.Errorf("MAX_DATA_LAG_DAYS must not be negative: %d", e.MaxDataLagDays)
+	}
+	if e.Concurrency < 1 {
+		return fmt.Errorf("CONCURRENCY must be positive: %d", e.Concurrency)
+	}
+	if e.CostExplorerMaxRetries < 0 {
+		return fmt.Errorf("COST_EXPLORER_MAX_RETRIES must not be negative: %d", e.CostExplorerMaxRetries)
+	}
+	if e.DeadlineMargin < 0 {
+		return fmt.Errorf("DEADLINE_MARGIN must not be negative: %s", e.DeadlineMargin)
+	}
+	if e.ConfigTTL < 0 {
+		return fmt.Errorf("CONFIG_TTL must not be negative: %s", e.ConfigTTL)
+	}
+	if e.CacheFinalizedDays < 1 {
+		return fmt.Errorf("CACHE_FINALIZED_DAYS must be positive: %d", e.CacheFinalizedDays)
+	}
+	switch e.Granularity {
+	case "DAILY", "MONTHLY":
+	default:
+		return fmt.Errorf("unsupported GRANULARITY %q", e.Granularity)
+	}
+	switch e.DryRunFormat {
+	case "table", "json":
+	default:
+		return fmt.Errorf("unsupported DRY_RUN_FORMAT %q", e.DryRunFormat)
+	}
+	switch e.SecretProvider {
+	case secretProviderSSM, secretProviderSecretsManager, secretProviderEnv:
+	default:
+		return fmt.Errorf("unsupported SECRET_PROVIDER %q", e.SecretProvider)
+	}
+	return nil
 }
 
-func init() {
-	cfg := envParameters{}
-	if err := env.Parse(&cfg); err != nil {
-		log.Fatal(errors.Wrap(err, "failed on env.Parse(&cfg)"))
+// LoadEnvs ... parse and validate the environment values
+func LoadEnvs() (EnvParameters, error) {
+	e := EnvParameters{}
+	if err := env.Parse(&e); err != nil {
+		return EnvParameters{}, errors.Wrap(err, "failed on env.Parse")
 	}
-	Envs = cfg
-
-	Session = session.Must(session.NewSession(&aws.Config{
-		Region: aws.String(Envs.AWSRegionID),
-	}))
-
-	ssmClient := awsapi.NewSSMClient(ssm.New(Session))
-	s, err := ssmClient.GetSSMParameters([]string{
-		Envs.DatadogAPIKeyName,
-		Envs.DatadogAppKeyName,
-	})
-	if err != nil {
-		log.Fatal(errors.Wrap(err, "failed on ssmClient.GetSSMParameters"))
-	}
-	ddAPIKey := s[Envs.DatadogAPIKeyName]
-	ddAPPKey := s[Envs.DatadogAppKeyName]
-
-	Secrets.DatadogAPIKey = ddAPIKey
-	Secrets.DatadogAppKey = ddAPPKey
+	if err := e.validate(); err != nil {
+		return EnvParameters{}, errors.Wrap(err, "invalid environment values")
+	}
+	return e, nil
 }
@@ -0,0 +1,213 @@
+package configs
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+var (
+	supportedSinks = []string{"datadog", "cloudwatch"}
+
+	// coverageDimensions : dimensions GetReservationCoverage accepts to group by
+	coverageDimensions = []string{
+		"AZ",
+		"CACHE_ENGINE",
+		"DATABASE_ENGINE",
+		"DEPLOYMENT_OPTION",
+		"INSTANCE_TYPE",
+		"LINKED_ACCOUNT",
+		"OPERATING_SYSTEM",
+		"PLATFORM",
+		"REGION",
+		"TENANCY",
+	}
+
+	// MetricNames : default names of the metrics posted by the handler, which metric_names can rename
+	MetricNames = []string{
+		"aws.ri.utilization",
+		"aws.ri.purchased_hours",
+		"aws.ri.total_actual_hours",
+		"aws.ri.unused_hours",
+		"aws.ri.on_demand_cost_of_ri_hours_used",
+		"aws.ri.net_savings",
+		"aws.ri.total_potential_savings",
+		"aws.ri.amortized_upfront_fee",
+		"aws.ri.amortized_recurring_fee",
+		"aws.ri.total_amortized_fee",
+		"aws.ri.subscription.utilization",
+		"aws.ri.subscription.unused_hours",
+		"aws.ri.coverage",
+		"aws.ri.coverage.on_demand_hours",
+		"aws.ri.coverage.reserved_hours",
+		"aws.ri.coverage.total_running_hours",
+		"aws.ri.coverage.on_demand_cost",
+		"aws.ri.coverage.normalized_units",
+		"aws.ri.coverage.on_demand_normalized_units",
+		"aws.ri.coverage.reserved_normalized_units",
+		"aws.ri.coverage.total_running_normalized_units",
+		"aws.ri.linked_account.utilization",
+		"aws.ri.linked_account.unused_hours",
+		"aws.ri.linked_account.coverage",
+		"aws.ri.linked_account.on_demand_hours",
+		"aws.ri.linked_account.on_demand_cost",
+		"aws.savingsplans.utilization",
+		"aws.savingsplans.arn.utilization",
+		"aws.savingsplans.arn.unused_commitment",
+		"aws.savingsplans.coverage",
+		"ri_plotter.run.errors",
+		"ri_plotter.data_lag_days",
+		"ri_plotter.cache.saved_calls",
+	}
+)
+
+// FileConfig : configuration file written in YAML or JSON
+type FileConfig struct {
+	// Services : services to collect, merged with SERVICES_ALLOW and SERVICES_DENY
+	Services ServicesConfig `yaml:"services"`
+	// CoverageGroupBy : dimensions to group RI coverage by, REGION and INSTANCE_TYPE if empty
+	CoverageGroupBy []string `yaml:"coverage_group_by"`
+	// MetricNames : names of metrics posted instead of the default ones, e.g. aws.ri.utilization
+	MetricNames map[string]string `yaml:"metric_names"`
+	// Tags : static tags added to every metric
+	Tags map[string]string `yaml:"tags"`
+	// Sinks : sinks overriding SINKS
+	Sinks []string `yaml:"sinks"`
+}
+
+// ServicesConfig : services of the configuration file
+type ServicesConfig struct {
+	// Discover : overrides DISCOVER_SERVICES if set
+	Discover *bool    `yaml:"discover"`
+	Allow    []string `yaml:"allow"`
+	Deny     []string `yaml:"deny"`
+}
+
+// parseFileConfig ... parse and validate the configuration file
+func parseFileConfig(data []byte) (FileConfig, error) {
+	f := FileConfig{}
+	// YAML is a superset of JSON, so that JSON is parsed as well
+	if err := yaml.UnmarshalStrict(data, &f); err != nil {
+		return FileConfig{}, errors.Wrap(err, "failed to parse")
+	}
+	if err := f.validate(); err != nil {
+		return FileConfig{}, err
+	}
+	return f, nil
+}
+
+// validate ... validate the values of the configuration file, reporting every invalid value
+func (f FileConfig) validate() error {
+	problems := []string{}
+
+	for i, s := range f.Sinks {
+		if !contains(supportedSinks, s) {
+			problems = append(problems, fmt.Sprintf("sinks[%d]: unsupported sink %q, must be one of %s", i, s, strings.Join(supportedSinks, ", ")))
+		}
+	}
+	for i, d := range f.CoverageGroupBy {
+		if !contains(coverageDimensions, d) {
+			problems = append(problems, fmt.Sprintf("coverage_group_by[%d]: unsupported dimension %q, must be one of %s", i, d, strings.Join(coverageDimensions, ", ")))
+		}
+	}
+	for _, k := range sortedKeys(f.MetricNames) {
+		if !contains(MetricNames, k) {
+			problems = append(problems, fmt.Sprintf("metric_names.%s: unknown metric, must be one of %s", k, strings.Join(MetricNames, ", ")))
+		} else if strings.TrimSpace(f.MetricNames[k]) == "" {
+			problems = append(problems, fmt.Sprintf("metric_names.%s: name is empty", k))
+		}
+	}
+	for _, k := range sortedKeys(f.Tags) {
+		if k == "" || strings.ContainsAny(k, ":,") {
+			problems = append(problems, fmt.Sprintf("tags: invalid key %q, must be non-empty without ':' and ','", k))
+		}
+	}
+	for i, s := range f.Services.Allow {
+		if strings.TrimSpace(s) == "" {
+			problems = append(problems, fmt.Sprintf("services.allow[%d]: service is empty", i))
+		}
+	}
+	for i, s := range f.Services.Deny {
+		if strings.TrimSpace(s) == "" {
+			problems = append(problems, fmt.Sprintf("services.deny[%d]: service is empty", i))
+		}
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid values: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// apply ... environment values overridden by the configuration file
+func (f FileConfig) apply(e EnvParameters) EnvParameters {
+	if len(f.Sinks) > 0 {
+		e.Sinks = f.Sinks
+	}
+	if f.Services.Discover != nil {
+		e.DiscoverServices = *f.Services.Discover
+	}
+	e.ServicesAllow = append(append([]string{}, e.ServicesAllow...), f.Services.Allow...)
+	e.ServicesDeny = append(append([]string{}, e.ServicesDeny...), f.Services.Deny...)
+	return e
+}
+
+// TagList ... static tags formatted as key:value, sorted by key
+func (f FileConfig) TagList() []string {
+	tags := []string{}
+	for _, k := range sortedKeys(f.Tags) {
+		tags = append(tags, fmt.Sprintf("%s:%s", k, f.Tags[k]))
+	}
+	return tags
+}
+
+// readConfigSource ... content of the configuration file
+//
+// The source is either s3://<bucket>/<key>, ssm:<parameter name> or a local path.
+func readConfigSource(source string, s3Client awsapi.S3Iface, ssmClient awsapi.SSMIface) ([]byte, error) {
+	switch {
+	case strings.HasPrefix(source, "s3://"):
+		location := strings.SplitN(strings.TrimPrefix(source, "s3://"), "/", 2)
+		if len(location) != 2 || location[0] == "" || location[1] == "" {
+			return nil, fmt.Errorf("invalid S3 location %q, must be s3://<bucket>/<key>", source)
+		}
+		return s3Client.GetObject(location[0], location[1])
+	case strings.HasPrefix(source, "ssm:"):
+		name := strings.TrimPrefix(source, "ssm:")
+		s, err := ssmClient.GetSSMParameters([]string{name})
+		if err != nil {
+			return nil, err
+		}
+		v, ok := s[name]
+		if !ok {
+			return nil, fmt.Errorf("SSM parameter %q is not found", name)
+		}
+		return []byte(v), nil
+	default:
+		return ioutil.ReadFile(strings.TrimPrefix(source, "file://"))
+	}
+}
+
+func contains(s []string, v string) bool {
+	for _, e := range s {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
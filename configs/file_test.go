@@ -0,0 +1,239 @@
+package configs
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+type mockS3 struct {
+	Objects map[string]string
+}
+
+func (m *mockS3) GetObject(bucket, key string) ([]byte, error) {
+	v, ok := m.Objects[bucket+"/"+key]
+	if !ok {
+		return nil, errors.New("NoSuchKey")
+	}
+	return []byte(v), nil
+}
+
+func (m *mockS3) PutObject(bucket, key string, body []byte) error {
+	m.Objects[bucket+"/"+key] = string(body)
+	return nil
+}
+
+type mockSSM struct {
+	Parameters map[string]string
+}
+
+func (m *mockSSM) GetSSMParameters(keys []string) (map[string]string, error) {
+	s := map[string]string{}
+	invalids := []string{}
+	for _, k := range keys {
+		v, ok := m.Parameters[k]
+		if !ok {
+			invalids = append(invalids, k)
+			continue
+		}
+		s[k] = v
+	}
+	if len(invalids) > 0 {
+		return nil, &awsapi.InvalidParametersError{Names: invalids}
+	}
+	return s, nil
+}
+
+func (m *mockSSM) GetSSMParametersByPath(path string) (map[string]string, error) {
+	s := map[string]string{}
+	for k, v := range m.Parameters {
+		if strings.HasPrefix(k, path+"/") {
+			s[k] = v
+		}
+	}
+	return s, nil
+}
+
+func TestParseFileConfig(t *testing.T) {
+	discover := false
+	expected := FileConfig{
+		Services: ServicesConfig{
+			Discover: &discover,
+			Allow:    []string{"Amazon MemoryDB"},
+			Deny:     []string{"Amazon Elasticsearch Service"},
+		},
+		CoverageGroupBy: []string{"REGION", "PLATFORM"},
+		MetricNames:     map[string]string{"aws.ri.utilization": "finops.ri.utilization"},
+		Tags:            map[string]string{"team": "finops", "env": "production"},
+		Sinks:           []string{"cloudwatch"},
+	}
+
+	tests := []struct {
+		name   string
+		source string
+	}{
+		{
+			name:   "yaml",
+			source: "testdata/config.yaml",
+		},
+		{
+			name:   "json",
+			source: "file://testdata/config.json",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readConfigSource(tt.source, &mockS3{}, &mockSSM{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			f, err := parseFileConfig(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(expected, f); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+func TestParseFileConfigFailed(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected string
+	}{
+		{
+			name:     "unknown field",
+			data:     "sink: [datadog]",
+			expected: "failed to parse: yaml: unmarshal errors:\n  line 1: field sink not found in type configs.FileConfig",
+		},
+		{
+			name: "every invalid value",
+			data: `
+sinks: [datadog, stdout]
+coverage_group_by: [INSTANCE_FAMILY]
+metric_names: {aws.ri.utilization: ""}
+tags: {"team:name": finops}
+services: {allow: [""]}
+`,
+			expected: `invalid values: sinks[1]: unsupported sink "stdout", must be one of datadog, cloudwatch; ` +
+				`coverage_group_by[0]: unsupported dimension "INSTANCE_FAMILY", must be one of AZ, CACHE_ENGINE, DATABASE_ENGINE, DEPLOYMENT_OPTION, INSTANCE_TYPE, LINKED_ACCOUNT, OPERATING_SYSTEM, PLATFORM, REGION, TENANCY; ` +
+				`metric_names.aws.ri.utilization: name is empty; ` +
+				`tags: invalid key "team:name", must be non-empty without ':' and ','; ` +
+				`services.allow[0]: service is empty`,
+		},
+		{
+			// 投稿しないメトリクス名は綴りの誤りとして報告する
+			name:     "unknown metric",
+			data:     "metric_names: {aws.ri.utilisation: finops.ri.utilization}",
+			expected: "invalid values: metric_names.aws.ri.utilisation: unknown metric, must be one of " + strings.Join(MetricNames, ", "),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseFileConfig([]byte(tt.data))
+			if err == nil {
+				t.Fatal("wrong result : err is nil")
+			}
+			if diff := cmp.Diff(tt.expected, err.Error()); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+func TestReadConfigSource(t *testing.T) {
+	s3Client := &mockS3{
+		Objects: map[string]string{"bucket/ri/config.yaml": "sinks: [datadog]"},
+	}
+	ssmClient := &mockSSM{
+		Parameters: map[string]string{"/ri/config": "sinks: [cloudwatch]"},
+	}
+
+	tests := []struct {
+		name     string
+		source   string
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "S3 object",
+			source:   "s3://bucket/ri/config.yaml",
+			expected: "sinks: [datadog]",
+		},
+		{
+			name:     "SSM parameter",
+			source:   "ssm:/ri/config",
+			expected: "sinks: [cloudwatch]",
+		},
+		{
+			name:    "S3 location without key",
+			source:  "s3://bucket",
+			wantErr: true,
+		},
+		{
+			name:    "SSM parameter not found",
+			source:  "ssm:/ri/unknown",
+			wantErr: true,
+		},
+		{
+			name:    "local file not found",
+			source:  "testdata/unknown.yaml",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := readConfigSource(tt.source, s3Client, ssmClient)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readConfigSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, string(data)); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// 設定ファイルの値で環境変数の値を上書き・追加する
+func TestApply(t *testing.T) {
+	discover := false
+	f := FileConfig{
+		Services: ServicesConfig{
+			Discover: &discover,
+			Allow:    []string{"Amazon MemoryDB"},
+		},
+		Sinks: []string{"cloudwatch"},
+	}
+	e := EnvParameters{
+		Sinks:            []string{"datadog"},
+		DiscoverServices: true,
+		ServicesAllow:    []string{"Amazon Redshift"},
+		ServicesDeny:     []string{"Amazon ElastiCache"},
+	}
+
+	expected := EnvParameters{
+		Sinks:            []string{"cloudwatch"},
+		DiscoverServices: false,
+		ServicesAllow:    []string{"Amazon Redshift", "Amazon MemoryDB"},
+		ServicesDeny:     []string{"Amazon ElastiCache"},
+	}
+	if diff := cmp.Diff(expected, f.apply(e)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestTagList(t *testing.T) {
+	f := FileConfig{
+		Tags: map[string]string{"team": "finops", "env": "production"},
+	}
+	if diff := cmp.Diff([]string{"env:production", "team:finops"}, f.TagList()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
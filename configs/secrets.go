@@ -0,0 +1,181 @@
+package configs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+const (
+	secretProviderSSM            = "ssm"
+	secretProviderSecretsManager = "secretsmanager"
+	secretProviderEnv            = "env"
+)
+
+// SecretParameters : secret parameters
+type SecretParameters struct {
+	DatadogAPIKey string
+	DatadogAppKey string
+}
+
+// validate ... every secret is required
+func (s SecretParameters) validate() error {
+	if s.DatadogAPIKey == "" {
+		return fmt.Errorf("datadog API key is empty")
+	}
+	if s.DatadogAppKey == "" {
+		return fmt.Errorf("datadog application key is empty")
+	}
+	return nil
+}
+
+// SecretProvider : provider of the secrets
+type SecretProvider interface {
+	Secrets(ctx context.Context) (SecretParameters, error)
+}
+
+// newSecretProvider ... the provider selected by SECRET_PROVIDER
+func newSecretProvider(e EnvParameters, sess *session.Session) (SecretProvider, error) {
+	switch e.SecretProvider {
+	case secretProviderSSM:
+		return NewSSMSecretProvider(awsapi.NewSSMClient(ssm.New(sess)), e.SSMPath, e.DatadogAPIKeyName, e.DatadogAppKeyName), nil
+	case secretProviderSecretsManager:
+		return NewSecretsManagerSecretProvider(awsapi.NewSecretsManager(secretsmanager.New(sess)), e.DatadogSecretID), nil
+	case secretProviderEnv:
+		return NewEnvSecretProvider(), nil
+	default:
+		return nil, fmt.Errorf("unsupported SECRET_PROVIDER %q", e.SecretProvider)
+	}
+}
+
+// ssmSecretProvider : provider of the secrets stored in SSM Parameter Store
+type ssmSecretProvider struct {
+	client     awsapi.SSMIface
+	path       string
+	apiKeyName string
+	appKeyName string
+}
+
+// NewSSMSecretProvider ... generate a provider of the secrets stored in SSM Parameter Store
+//
+// The names are relative to the path if it is not empty, e.g. /datadog/api_key with the path /datadog and the name api_key.
+func NewSSMSecretProvider(client awsapi.SSMIface, path, apiKeyName, appKeyName string) SecretProvider {
+	return &ssmSecretProvider{
+		client:     client,
+		path:       path,
+		apiKeyName: apiKeyName,
+		appKeyName: appKeyName,
+	}
+}
+
+// Secrets ... values of the SSM parameters
+func (p *ssmSecretProvider) Secrets(ctx context.Context) (SecretParameters, error) {
+	if err := ctx.Err(); err != nil {
+		return SecretParameters{}, err
+	}
+
+	apiKeyName, appKeyName := p.apiKeyName, p.appKeyName
+	var s map[string]string
+	var err error
+	if p.path == "" {
+		if s, err = p.client.GetSSMParameters([]string{apiKeyName, appKeyName}); err != nil {
+			return SecretParameters{}, errors.Wrap(err, "failed on GetSSMParameters")
+		}
+	} else {
+		apiKeyName = path.Join(p.path, apiKeyName)
+		appKeyName = path.Join(p.path, appKeyName)
+		if s, err = p.client.GetSSMParametersByPath(p.path); err != nil {
+			return SecretParameters{}, errors.Wrap(err, "failed on GetSSMParametersByPath")
+		}
+	}
+
+	for _, name := range []string{apiKeyName, appKeyName} {
+		if _, ok := s[name]; !ok {
+			return SecretParameters{}, &awsapi.InvalidParametersError{Names: []string{name}}
+		}
+	}
+	return SecretParameters{
+		DatadogAPIKey: s[apiKeyName],
+		DatadogAppKey: s[appKeyName],
+	}, nil
+}
+
+// secretsManagerSecretProvider : provider of the secrets stored in Secrets Manager
+type secretsManagerSecretProvider struct {
+	client   awsapi.SecretsManagerIface
+	secretID string
+}
+
+// NewSecretsManagerSecretProvider ... generate a provider of the secrets stored in Secrets Manager
+//
+// The secret is a JSON object with the fields api_key and app_key.
+func NewSecretsManagerSecretProvider(client awsapi.SecretsManagerIface, secretID string) SecretProvider {
+	return &secretsManagerSecretProvider{
+		client:   client,
+		secretID: secretID,
+	}
+}
+
+// Secrets ... fields of the JSON secret
+func (p *secretsManagerSecretProvider) Secrets(ctx context.Context) (SecretParameters, error) {
+	if err := ctx.Err(); err != nil {
+		return SecretParameters{}, err
+	}
+	v, err := p.client.GetSecretString(p.secretID)
+	if err != nil {
+		return SecretParameters{}, errors.Wrap(err, "failed on GetSecretString")
+	}
+
+	keys := struct {
+		APIKey string `json:"api_key"`
+		AppKey string `json:"app_key"`
+	}{}
+	if err := json.Unmarshal([]byte(v), &keys); err != nil {
+		return SecretParameters{}, errors.Wrap(err, fmt.Sprintf("secret %s is not a JSON object with api_key and app_key", p.secretID))
+	}
+	return SecretParameters{
+		DatadogAPIKey: keys.APIKey,
+		DatadogAppKey: keys.AppKey,
+	}, nil
+}
+
+// envSecretProvider : provider of the secrets set in environment variables, for local runs
+type envSecretProvider struct{}
+
+// NewEnvSecretProvider ... generate a provider of the secrets set in DD_API_KEY and DD_APP_KEY
+func NewEnvSecretProvider() SecretProvider {
+	return &envSecretProvider{}
+}
+
+// Secrets ... values of DD_API_KEY and DD_APP_KEY
+func (p *envSecretProvider) Secrets(ctx context.Context) (SecretParameters, error) {
+	return SecretParameters{
+		DatadogAPIKey: os.Getenv("DD_API_KEY"),
+		DatadogAppKey: os.Getenv("DD_APP_KEY"),
+	}, nil
+}
+
+// resolveSecrets ... secrets required by the sinks
+func resolveSecrets(ctx context.Context, e EnvParameters, provider SecretProvider) (SecretParameters, error) {
+	if !contains(e.Sinks, "datadog") {
+		return SecretParameters{}, nil
+	}
+
+	s, err := provider.Secrets(ctx)
+	if err != nil {
+		return SecretParameters{}, err
+	}
+	if err := s.validate(); err != nil {
+		return SecretParameters{}, errors.Wrap(err, fmt.Sprintf("invalid secrets of %s", e.SecretProvider))
+	}
+	return s, nil
+}
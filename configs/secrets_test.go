@@ -0,0 +1,128 @@
+package configs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockSecretsManager struct {
+	Secrets map[string]string
+}
+
+func (m *mockSecretsManager) GetSecretString(secretID string) (string, error) {
+	v, ok := m.Secrets[secretID]
+	if !ok {
+		return "", errors.New("ResourceNotFoundException")
+	}
+	return v, nil
+}
+
+func TestSecretProviders(t *testing.T) {
+	os.Setenv("DD_API_KEY", "hogehoge")
+	os.Setenv("DD_APP_KEY", "mogemoge")
+	defer os.Unsetenv("DD_API_KEY")
+	defer os.Unsetenv("DD_APP_KEY")
+
+	ssmClient := &mockSSM{
+		Parameters: map[string]string{
+			"datadog_api_key":  "hogehoge",
+			"datadog_app_key":  "mogemoge",
+			"/datadog/api_key": "hogehoge",
+			"/datadog/app_key": "mogemoge",
+		},
+	}
+	secretsManager := &mockSecretsManager{
+		Secrets: map[string]string{
+			"datadog":      `{"api_key":"hogehoge","app_key":"mogemoge"}`,
+			"not-json":     "hogehoge",
+			"datadog-test": `{"api_key":"hogehoge"}`,
+		},
+	}
+
+	tests := []struct {
+		name     string
+		provider SecretProvider
+		expected SecretParameters
+		wantErr  bool
+	}{
+		{
+			name:     "SSM Parameter Store",
+			provider: NewSSMSecretProvider(ssmClient, "", "datadog_api_key", "datadog_app_key"),
+			expected: SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"},
+		},
+		{
+			name:     "SSM parameter not found",
+			provider: NewSSMSecretProvider(ssmClient, "", "datadog_api_key", "unknown"),
+			wantErr:  true,
+		},
+		{
+			// path 配下の名前は path からの相対名で指定する
+			name:     "SSM parameters under the path",
+			provider: NewSSMSecretProvider(ssmClient, "/datadog", "api_key", "app_key"),
+			expected: SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"},
+		},
+		{
+			name:     "SSM parameter not found under the path",
+			provider: NewSSMSecretProvider(ssmClient, "/datadog", "api_key", "unknown"),
+			wantErr:  true,
+		},
+		{
+			name:     "Secrets Manager",
+			provider: NewSecretsManagerSecretProvider(secretsManager, "datadog"),
+			expected: SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"},
+		},
+		{
+			// app_key のない secret は空のまま返し、resolveSecrets で検証する
+			name:     "Secrets Manager without app_key",
+			provider: NewSecretsManagerSecretProvider(secretsManager, "datadog-test"),
+			expected: SecretParameters{DatadogAPIKey: "hogehoge"},
+		},
+		{
+			name:     "Secrets Manager secret is not JSON",
+			provider: NewSecretsManagerSecretProvider(secretsManager, "not-json"),
+			wantErr:  true,
+		},
+		{
+			name:     "Secrets Manager secret not found",
+			provider: NewSecretsManagerSecretProvider(secretsManager, "unknown"),
+			wantErr:  true,
+		},
+		{
+			name:     "environment variables",
+			provider: NewEnvSecretProvider(),
+			expected: SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := tt.provider.Secrets(context.Background())
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Secrets() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, s); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+func TestNewSecretProvider(t *testing.T) {
+	sess := session.Must(session.NewSession(&aws.Config{
+		Region: aws.String(endpoints.UsEast1RegionID),
+	}))
+	for _, name := range []string{"ssm", "secretsmanager", "env"} {
+		if _, err := newSecretProvider(EnvParameters{SecretProvider: name}, sess); err != nil {
+			t.Errorf("wrong result : %s", err)
+		}
+	}
+	if _, err := newSecretProvider(EnvParameters{SecretProvider: "vault"}, sess); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
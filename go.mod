@@ -6,11 +6,11 @@ require (
 	github.com/aws/aws-lambda-go v1.15.0
 	github.com/aws/aws-sdk-go v1.29.24
 	github.com/caarlos0/env v3.5.0+incompatible
-	github.com/cenkalti/backoff v2.2.1+incompatible // indirect
+	github.com/cenkalti/backoff v2.2.1+incompatible
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/google/go-cmp v0.3.1
 	github.com/jmespath/go-jmespath v0.3.0 // indirect
 	github.com/pkg/errors v0.9.1
 	github.com/zorkian/go-datadog-api v2.28.0+incompatible
-	gopkg.in/yaml.v2 v2.2.8 // indirect
+	gopkg.in/yaml.v2 v2.2.8
 )
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/credentials/stscreds"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+var accountIDPattern = regexp.MustCompile(`^\d{12}$`)
+
+// account : AWS account whose Cost Explorer data is collected
+//
+// The zero value is the account of the Lambda, collected without assuming a role.
+type account struct {
+	id      string
+	alias   string
+	roleARN string
+}
+
+// target : account and its Cost Explorer client
+type target struct {
+	account
+	client awsapi.CostexplorerIface
+	// services : services discovered for the run
+	services []string
+	// linkedAccountNames : names of the linked accounts keyed by account ID, nil unless they are resolved
+	linkedAccountNames map[string]string
+	// dataLagDays : days the period of the discovery is shifted back to find data
+	dataLagDays int
+}
+
+// parseAccounts ... accounts of the entries formatted as [alias=]<account ID or role ARN>
+//
+// The role named roleName is assumed in an account specified by its ID.
+// No entry means the account of the Lambda.
+func parseAccounts(entries []string, roleName string) ([]account, error) {
+	accounts := []account{}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		alias := ""
+		if i := strings.Index(entry, "="); i >= 0 {
+			alias, entry = entry[:i], entry[i+1:]
+		}
+
+		a := account{}
+		if accountIDPattern.MatchString(entry) {
+			a.id = entry
+			a.roleARN = fmt.Sprintf("arn:aws:iam::%s:role/%s", entry, roleName)
+		} else {
+			r, err := arn.Parse(entry)
+			if err != nil {
+				return nil, errors.Wrap(err, fmt.Sprintf("invalid account %q", entry))
+			}
+			a.id = r.AccountID
+			a.roleARN = entry
+		}
+		a.alias = a.id
+		if alias != "" {
+			a.alias = alias
+		}
+		accounts = append(accounts, a)
+	}
+
+	if len(accounts) == 0 {
+		return []account{{}}, nil
+	}
+	return accounts, nil
+}
+
+// tags ... tags identifying the account, none for the account of the Lambda
+func (a account) tags() []string {
+	if a.id == "" {
+		return []string{}
+	}
+	return []string{
+		fmt.Sprintf("account_id:%s", a.id),
+		fmt.Sprintf("account_alias:%s", a.alias),
+	}
+}
+
+// accountConfigs ... configs of AWS clients with the credentials of the role assumed in the account
+func accountConfigs(a account) []*aws.Config {
+	if a.roleARN == "" {
+		return []*aws.Config{}
+	}
+	return []*aws.Config{
+		{Credentials: stscreds.NewCredentials(cfg.Session, a.roleARN)},
+	}
+}
+
+// newAccountCostexplorer ... generate the Cost Explorer client of the account
+//
+// The retryer of the SDK is disabled, since awsapi.WithRetry retries the requests with backoff.
+func newAccountCostexplorer(a account, options ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+	configs := append(accountConfigs(a), &aws.Config{MaxRetries: aws.Int(0)})
+	return awsapi.NewCostexplorer(costexplorer.New(cfg.Session, configs...), options...)
+}
+
+// newAccountOrganizations ... generate the Organizations client of the account
+func newAccountOrganizations(a account) awsapi.OrganizationsIface {
+	return awsapi.NewOrganizations(organizations.New(cfg.Session, accountConfigs(a)...))
+}
+
+// newAccountSTS ... generate the STS client of the account
+func newAccountSTS(a account) awsapi.STSIface {
+	return awsapi.NewSTS(sts.New(cfg.Session, accountConfigs(a)...))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+)
+
+func TestParseAccounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		expected []account
+		wantErr  bool
+	}{
+		{
+			name:     "the account of the Lambda if no account is specified",
+			entries:  []string{},
+			expected: []account{{}},
+		},
+		{
+			name:    "account IDs and role ARNs with or without alias",
+			entries: []string{"111111111111", " production=222222222222", "staging=arn:aws:iam::333333333333:role/plotter", ""},
+			expected: []account{
+				{id: "111111111111", alias: "111111111111", roleARN: "arn:aws:iam::111111111111:role/ri-utilization-plotter"},
+				{id: "222222222222", alias: "production", roleARN: "arn:aws:iam::222222222222:role/ri-utilization-plotter"},
+				{id: "333333333333", alias: "staging", roleARN: "arn:aws:iam::333333333333:role/plotter"},
+			},
+		},
+		{
+			name:    "neither account ID nor role ARN",
+			entries: []string{"production=12345"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accounts, err := parseAccounts(tt.entries, "ri-utilization-plotter")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseAccounts() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, accounts, cmp.AllowUnexported(account{})); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// アカウントごとに収集したメトリクスに account_id と alias のタグを付ける
+func TestCollectAccounts(t *testing.T) {
+	client := &mockCostexplorer{
+		riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+	}
+	targets := []target{
+		{account: account{id: "111111111111", alias: "production"}, client: client},
+		{account: account{id: "222222222222", alias: "staging"}, client: client},
+	}
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	metrics, err := collect(context.Background(), targets, Event{Service: "Amazon Redshift", CEMetricType: "utilization"}, []window{w})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actual := []string{}
+	for _, m := range metrics {
+		if m.Name == "aws.ri.utilization" {
+			actual = append(actual, summarize([]sink.Metric{m})...)
+		}
+	}
+	expected := []string{
+		"aws.ri.utilization account:yourproject,yourproject,service:Amazon Redshift,account_id:111111111111,account_alias:production 100",
+		"aws.ri.utilization account:yourproject,yourproject,service:Amazon Redshift,account_id:222222222222,account_alias:staging 100",
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// アカウントの収集に失敗したらどのアカウントかがわかるエラーを返す
+func TestCollectAccountsFailed(t *testing.T) {
+	targets := []target{
+		{account: account{id: "111111111111", alias: "production"}, client: &mockCostexplorer{Error: errors.New("AccessDenied")}},
+	}
+	_, err := collect(context.Background(), targets, Event{Service: "Amazon Redshift"}, []window{{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}})
+	if err == nil {
+		t.Fatal("wrong result : err is nil")
+	}
+	if expected := "collect: period: 2020-03-01 - 2020-03-03: account: production (111111111111): service: Amazon Redshift: on costexplorerClient.FetchRIUtilization: AccessDenied"; err.Error() != expected {
+		t.Errorf("wrong result : %s", err)
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/cache"
+)
+
+// newResponseCache ... cache of Cost Explorer responses in the store of the source, nil if the source is empty
+//
+// The source is either s3://<bucket>[/<prefix>], dynamodb:<table> or a local directory.
+func newResponseCache(source string, finalizedDays int) (*cache.Cache, error) {
+	var store cache.Store
+	switch {
+	case source == "":
+		return nil, nil
+	case strings.HasPrefix(source, "s3://"):
+		location := strings.SplitN(strings.TrimPrefix(source, "s3://"), "/", 2)
+		if location[0] == "" {
+			return nil, fmt.Errorf("invalid S3 location %q, must be s3://<bucket>[/<prefix>]", source)
+		}
+		prefix := ""
+		if len(location) == 2 {
+			prefix = location[1]
+		}
+		store = cache.NewS3(awsapi.NewS3(s3.New(cfg.Session)), location[0], prefix)
+	case strings.HasPrefix(source, "dynamodb:"):
+		table := strings.TrimPrefix(source, "dynamodb:")
+		if table == "" {
+			return nil, fmt.Errorf("invalid DynamoDB table %q, must be dynamodb:<table>", source)
+		}
+		store = cache.NewDynamoDB(awsapi.NewDynamoDB(dynamodb.New(cfg.Session)), table)
+	default:
+		store = cache.NewFile(strings.TrimPrefix(source, "file://"))
+	}
+	// the periods are finalized by the clock deciding the reporting window
+	return cache.New(store, finalizedDays, now), nil
+}
+
+// cacheAccountID ... ID of the account in the keys of the cache, resolved by STS for the account of the Lambda
+func cacheAccountID(a account) (string, error) {
+	if a.id != "" {
+		return a.id, nil
+	}
+	id, err := newSTS(a).GetAccountID()
+	if err != nil {
+		return "", errors.Wrap(err, "on GetAccountID")
+	}
+	return id, nil
+}
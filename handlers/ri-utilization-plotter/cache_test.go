@@ -0,0 +1,298 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zorkian/go-datadog-api"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+type mockSTS struct {
+	accountID string
+	Error     error
+}
+
+func (m *mockSTS) GetAccountID() (string, error) {
+	return m.accountID, m.Error
+}
+
+func TestNewResponseCache(t *testing.T) {
+	tests := []struct {
+		source  string
+		isNil   bool
+		wantErr bool
+	}{
+		{source: "", isNil: true},
+		{source: "/tmp/ri-utilization-plotter"},
+		{source: "file:///tmp/ri-utilization-plotter"},
+		{source: "s3://bucket"},
+		{source: "s3://bucket/ri-utilization-plotter/cache"},
+		{source: "s3:///cache", wantErr: true},
+		{source: "dynamodb:ri-utilization-plotter-cache"},
+		{source: "dynamodb:", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			c, err := newResponseCache(tt.source, 3)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("newResponseCache() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && (c == nil) != tt.isNil {
+				t.Errorf("wrong result : %v", c)
+			}
+		})
+	}
+}
+
+// 期間が確定したかはハンドラーの時計で判断する
+func TestResponseCacheClock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		requests int
+	}{
+		// 2020-03-02 までの期間は 3 日後に確定する
+		{name: "not finalized", now: time.Date(2020, 3, 4, 10, 0, 0, 0, time.UTC), requests: 2},
+		{name: "finalized", now: time.Date(2020, 3, 5, 10, 0, 0, 0, time.UTC), requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now = func() time.Time { return tt.now }
+			responseCache, err := newResponseCache(dir, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := &mockCostexplorer{
+				riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+			}
+			cached := responseCache.Wrap(client, tt.name)
+			for i := 0; i < 2; i++ {
+				if _, err := cached.FetchRIUtilization("Amazon Redshift", "2020-03-01", "2020-03-02", "DAILY"); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if len(client.periods) != tt.requests {
+				t.Errorf("wrong result : %d requests", len(client.periods))
+			}
+		})
+	}
+}
+
+// 確定した期間を再度収集するときは Cost Explorer へリクエストせず、節約したリクエスト数を送信する
+func TestHandlerCached(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	store := cfg.Envs.CacheStore
+	defer func() { cfg.Envs.CacheStore = store }()
+	cfg.Envs.CacheStore = dir
+
+	// datadog へ送信された ri_plotter.cache.saved_calls
+	var saved []float64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Series []datadog.Metric `json:"series"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		for _, m := range body.Series {
+			if *m.Metric == cacheSavedMetric {
+				saved = append(saved, *m.Points[0][1])
+			}
+		}
+		w.WriteHeader(202)
+	}))
+	defer ts.Close()
+	datadogClient = &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	datadogClient.SetBaseUrl(ts.URL)
+
+	client := &mockCostexplorer{
+		riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+	}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return client
+	}
+	newSTS = func(account) awsapi.STSIface {
+		return &mockSTS{accountID: "111111111111"}
+	}
+
+	event := Event{Service: "Amazon Redshift", StartDay: "2020-03-01", EndDay: "2020-03-03", Backfill: true}
+	for i := 0; i < 2; i++ {
+		if err := handler(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// utilization と coverage を 2 日分
+	if len(client.periods) != 4 {
+		t.Errorf("wrong result : %d requests", len(client.periods))
+	}
+	if len(saved) != 2 || saved[0] != 0 || saved[1] != 4 {
+		t.Errorf("wrong result : %v", saved)
+	}
+}
+
+// Lambda のアカウントはキャッシュのキーに STS で解決したアカウント ID を使う
+func TestCacheAccountID(t *testing.T) {
+	tests := []struct {
+		name     string
+		account  account
+		sts      *mockSTS
+		expected string
+		wantErr  bool
+	}{
+		{
+			name:     "account of the Lambda",
+			account:  account{},
+			sts:      &mockSTS{accountID: "111111111111"},
+			expected: "111111111111",
+		},
+		{
+			// 指定されたアカウントは STS にリクエストしない
+			name:     "specified account",
+			account:  account{id: "222222222222", alias: "staging"},
+			sts:      &mockSTS{Error: errors.New("unexpected request")},
+			expected: "222222222222",
+		},
+		{
+			name:    "sts error",
+			account: account{},
+			sts:     &mockSTS{Error: errors.New("ExpiredToken")},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			newSTS = func(account) awsapi.STSIface {
+				return tt.sts
+			}
+			id, err := cacheAccountID(tt.account)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("cacheAccountID() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, id); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// アカウント ID を解決できなければキャッシュせずに収集する
+func TestNewTargetsWithoutAccountID(t *testing.T) {
+	responseCache, err := newResponseCache("s3://bucket", 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &mockCostexplorer{}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return client
+	}
+	newSTS = func(account) awsapi.STSIface {
+		return &mockSTS{Error: errors.New("ExpiredToken")}
+	}
+
+	event := Event{Service: "Amazon Redshift"}
+	targets, errs := newTargets(context.Background(), []account{{}}, event, nil, nil, responseCache)
+	if len(targets) != 1 || targets[0].client != client {
+		t.Fatalf("wrong result : %v", targets)
+	}
+	if len(errs) != 1 || errs[0].stage != stageCache {
+		t.Errorf("wrong result : %v", errs)
+	}
+}
+
+// 定期実行の期間のうち確定した日はキャッシュから返し、2 回目の実行で節約したリクエスト数を送信する
+func TestHandlerCachedScheduled(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	envs := cfg.Envs
+	defer func() {
+		cfg.Envs = envs
+		now = time.Now
+	}()
+	cfg.Envs.CacheStore = dir
+	cfg.Envs.LookbackDays = 5
+	cfg.Envs.CacheFinalizedDays = 3
+	cfg.Envs.SavingsPlans = true
+
+	var saved []float64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Series []datadog.Metric `json:"series"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		for _, m := range body.Series {
+			if *m.Metric == cacheSavedMetric {
+				saved = append(saved, *m.Points[0][1])
+			}
+		}
+		w.WriteHeader(202)
+	}))
+	defer ts.Close()
+	datadogClient = &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	datadogClient.SetBaseUrl(ts.URL)
+
+	client := &mockCostexplorer{
+		reservedServices: []string{"Amazon Redshift"},
+		riUtil:           &awsapi.RIUtilization{UtilizationPercentage: 100},
+		spUtil:           &awsapi.SavingsPlansUtilization{UtilizationPercentage: 90},
+		spDetails:        []*awsapi.SavingsPlansUtilization{{SavingsPlanArn: "arn:aws:savingsplans::111111111111:savingsplan/a", UtilizationPercentage: 90}},
+	}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return client
+	}
+	newSTS = func(account) awsapi.STSIface {
+		return &mockSTS{accountID: "111111111111"}
+	}
+
+	// 2020-03-05 から 2020-03-10 のうち 2020-03-07 までに終わる日が確定している
+	for _, hour := range []int{10, 22} {
+		now = func() time.Time { return time.Date(2020, 3, 10, hour, 0, 0, 0, time.UTC) }
+		if err := handler(context.Background(), Event{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(saved) != 2 || saved[0] != 0 || saved[1] == 0 {
+		t.Errorf("wrong result : %v", saved)
+	}
+	// RI の利用率と coverage は確定していない日だけを取得し直す
+	expected := []string{
+		"2020-03-05 2020-03-10 DAILY",
+		"2020-03-05 2020-03-10 DAILY",
+		"2020-03-07 2020-03-10 DAILY",
+		"2020-03-07 2020-03-10 DAILY",
+	}
+	if diff := cmp.Diff(expected, client.periods); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+)
+
+const (
+	dateLayout = "2006-01-02"
+
+	granularityDaily   = "DAILY"
+	granularityMonthly = "MONTHLY"
+
+	metricTypeUtilization = "utilization"
+	metricTypeCoverage    = "coverage"
+)
+
+// Event : payload of the Lambda invocation
+//
+// Every field is optional. An empty event (e.g. the one sent by the CloudWatch Events schedule)
+// collects every metric type of the default services for the default period.
+type Event struct {
+	// Service : a single service to collect, e.g. "Amazon Redshift"
+	Service string `json:"service"`
+	// Services : services to collect, merged with Service
+	Services []string `json:"services"`
+	// StartDay : inclusive start of the period, formatted as YYYY-MM-DD
+	StartDay string `json:"start_day"`
+	// EndDay : exclusive end of the period, formatted as YYYY-MM-DD
+	EndDay string `json:"end_day"`
+	// Granularity : DAILY or MONTHLY
+	Granularity string `json:"granularity"`
+	// CEMetricType : utilization or coverage, empty means both
+	CEMetricType string `json:"ce_metric_type"`
+	// LinkedAccount : ID of a linked account of the organization to restrict the data to
+	LinkedAccount string `json:"linked_account"`
+	// Backfill : collect each day from StartDay to EndDay and plot the data points at the day
+	Backfill bool `json:"backfill"`
+	// DryRun : print the metrics to stdout instead of posting them, enabled by DRY_RUN as well
+	DryRun bool `json:"dry_run"`
+	// DryRunFormat : table or json, DRY_RUN_FORMAT if empty
+	DryRunFormat string `json:"dry_run_format"`
+}
+
+// validate ... validate the values of the event
+func (e Event) validate() error {
+	for _, d := range []string{e.StartDay, e.EndDay} {
+		if d == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, d); err != nil {
+			return errors.Wrap(err, fmt.Sprintf("invalid date %q", d))
+		}
+	}
+	if e.StartDay != "" && e.EndDay != "" && e.StartDay >= e.EndDay {
+		return fmt.Errorf("start_day %q must be before end_day %q", e.StartDay, e.EndDay)
+	}
+
+	switch e.Granularity {
+	case "", granularityDaily, granularityMonthly:
+	default:
+		return fmt.Errorf("unsupported granularity %q", e.Granularity)
+	}
+
+	switch e.CEMetricType {
+	case "", metricTypeUtilization, metricTypeCoverage:
+	default:
+		return fmt.Errorf("unsupported ce_metric_type %q", e.CEMetricType)
+	}
+
+	if e.LinkedAccount != "" && !accountIDPattern.MatchString(e.LinkedAccount) {
+		return fmt.Errorf("invalid linked_account %q", e.LinkedAccount)
+	}
+
+	switch e.DryRunFormat {
+	case "", sink.FormatTable, sink.FormatJSON:
+	default:
+		return fmt.Errorf("unsupported dry_run_format %q", e.DryRunFormat)
+	}
+
+	if e.Backfill {
+		if e.StartDay == "" {
+			return fmt.Errorf("start_day is required to backfill")
+		}
+		if e.Granularity != "" && e.Granularity != granularityDaily {
+			return fmt.Errorf("granularity must be %s to backfill", granularityDaily)
+		}
+	}
+	return nil
+}
+
+// targetServices ... services to collect, defaults if the event specifies none
+func (e Event) targetServices(defaults []string) []string {
+	s := []string{}
+	if e.Service != "" {
+		s = append(s, e.Service)
+	}
+	s = append(s, e.Services...)
+	if len(s) == 0 {
+		return defaults
+	}
+	return s
+}
+
+// period ... start and end of the period, defaults for unspecified fields
+func (e Event) period(defaultStart, defaultEnd string) (start, end string) {
+	start, end = defaultStart, defaultEnd
+	if e.StartDay != "" {
+		start = e.StartDay
+	}
+	if e.EndDay != "" {
+		end = e.EndDay
+	}
+	return start, end
+}
+
+// latest ... whether the period is the default one ending today, whose newest days may have no data yet
+func (e Event) latest() bool {
+	return e.StartDay == "" && e.EndDay == "" && !e.Backfill
+}
+
+// dryRunFormat ... format of the metrics printed by a dry run, the default if the event specifies none
+func (e Event) dryRunFormat(defaultFormat string) string {
+	if e.DryRunFormat == "" {
+		return defaultFormat
+	}
+	return e.DryRunFormat
+}
+
+// granularity ... granularity of Cost Explorer data, the default if the event specifies none
+func (e Event) granularity(defaultGranularity string) string {
+	if e.Granularity == "" {
+		return defaultGranularity
+	}
+	return e.Granularity
+}
+
+// collects ... whether the metric type is collected
+func (e Event) collects(metricType string) bool {
+	return e.CEMetricType == "" || e.CEMetricType == metricType
+}
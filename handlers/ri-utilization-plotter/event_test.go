@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestEventValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		event   Event
+		wantErr bool
+	}{
+		{
+			name:    "empty event",
+			event:   Event{},
+			wantErr: false,
+		},
+		{
+			name: "all fields",
+			event: Event{
+				Service:      "Amazon Redshift",
+				StartDay:     "2020-03-01",
+				EndDay:       "2020-03-02",
+				Granularity:  "MONTHLY",
+				CEMetricType: "utilization",
+			},
+			wantErr: false,
+		},
+		{
+			name:    "invalid date",
+			event:   Event{StartDay: "2020/03/01"},
+			wantErr: true,
+		},
+		{
+			name:    "start day is after end day",
+			event:   Event{StartDay: "2020-03-02", EndDay: "2020-03-01"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported granularity",
+			event:   Event{Granularity: "HOURLY"},
+			wantErr: true,
+		},
+		{
+			name:    "backfill",
+			event:   Event{StartDay: "2020-03-01", Backfill: true},
+			wantErr: false,
+		},
+		{
+			name:    "dry run in json",
+			event:   Event{DryRun: true, DryRunFormat: "json"},
+			wantErr: false,
+		},
+		{
+			name:    "unsupported dry run format",
+			event:   Event{DryRun: true, DryRunFormat: "yaml"},
+			wantErr: true,
+		},
+		{
+			name:    "backfill without start day",
+			event:   Event{Backfill: true},
+			wantErr: true,
+		},
+		{
+			name:    "backfill by month",
+			event:   Event{StartDay: "2020-03-01", Granularity: "MONTHLY", Backfill: true},
+			wantErr: true,
+		},
+		{
+			name:    "linked account",
+			event:   Event{LinkedAccount: "123456789012"},
+			wantErr: false,
+		},
+		{
+			name:    "invalid linked account",
+			event:   Event{LinkedAccount: "1234"},
+			wantErr: true,
+		},
+		{
+			name:    "unsupported metric type",
+			event:   Event{CEMetricType: "savings"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.event.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestEventDefaults(t *testing.T) {
+	defaults := []string{"Amazon Redshift", "Amazon ElastiCache"}
+
+	var e Event
+	if err := json.Unmarshal([]byte(`{}`), &e); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(defaults, e.targetServices(defaults)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	start, end := e.period("2020-03-01", "2020-03-03")
+	if diff := cmp.Diff([]string{"2020-03-01", "2020-03-03"}, []string{start, end}); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if diff := cmp.Diff("DAILY", e.granularity("DAILY")); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if !e.collects(metricTypeUtilization) || !e.collects(metricTypeCoverage) {
+		t.Error("wrong result : empty event must collect every metric type")
+	}
+}
+
+func TestEventOverrides(t *testing.T) {
+	var e Event
+	if err := json.Unmarshal([]byte(`{
+		"service": "Amazon Redshift",
+		"services": ["Amazon ElastiCache"],
+		"start_day": "2020-02-01",
+		"granularity": "MONTHLY",
+		"ce_metric_type": "coverage"
+	}`), &e); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"Amazon Redshift", "Amazon ElastiCache"}
+	if diff := cmp.Diff(expected, e.targetServices([]string{"Amazon Relational Database Service"})); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	start, end := e.period("2020-03-01", "2020-03-03")
+	if diff := cmp.Diff([]string{"2020-02-01", "2020-03-03"}, []string{start, end}); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if diff := cmp.Diff("MONTHLY", e.granularity("DAILY")); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if e.collects(metricTypeUtilization) || !e.collects(metricTypeCoverage) {
+		t.Error("wrong result : only coverage must be collected")
+	}
+}
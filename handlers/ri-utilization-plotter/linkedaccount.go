@@ -0,0 +1,69 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/utility"
+)
+
+// collectLinkedAccounts ... metrics of RI utilization and coverage of each linked account of the organization
+//
+// names are the account names keyed by account ID, nil unless they are resolved.
+func collectLinkedAccounts(costexplorerClient awsapi.CostexplorerIface, names map[string]string, service string, event Event, w window, tagKey, tagVal string) ([]sink.Metric, error) {
+	metrics := []sink.Metric{}
+
+	if event.collects(metricTypeUtilization) {
+		// the utilization of each linked account covers the whole period requested, so that it is requested for each time period
+		// to plot it at the same timestamps as the coverage of the same period
+		periods, err := w.periods()
+		if err != nil {
+			return nil, errors.Wrap(err, "on w.periods")
+		}
+		for _, p := range periods {
+			utils, err := costexplorerClient.FetchRIUtilizationByLinkedAccount(service, p.start, p.end)
+			if err != nil {
+				return nil, errors.Wrap(err, "on costexplorerClient.FetchRIUtilizationByLinkedAccount")
+			}
+			for _, u := range utils {
+				tags := linkedAccountTags(service, u.LinkedAccount, names, tagKey, tagVal)
+				metrics = append(metrics, aggregateMetrics(tags, u.Utilization.Start, []aggregate{
+					{"aws.ri.linked_account.utilization", u.Utilization.UtilizationPercentage, sink.UnitPercent},
+					{"aws.ri.linked_account.unused_hours", u.Utilization.UnusedHours, sink.UnitHour},
+				})...)
+			}
+		}
+	}
+
+	if event.collects(metricTypeCoverage) {
+		coverages, err := costexplorerClient.FetchRICoverageByLinkedAccount(service, w.start, w.end, w.granularity)
+		if err != nil {
+			return nil, errors.Wrap(err, "on costexplorerClient.FetchRICoverageByLinkedAccount")
+		}
+		for _, c := range coverages {
+			tags := linkedAccountTags(service, c.LinkedAccount, names, tagKey, tagVal)
+			metrics = append(metrics, aggregateMetrics(tags, c.Start, []aggregate{
+				{"aws.ri.linked_account.coverage", c.CoverageHoursPercentage, sink.UnitPercent},
+				{"aws.ri.linked_account.on_demand_hours", c.OnDemandHours, sink.UnitHour},
+				{"aws.ri.linked_account.on_demand_cost", c.OnDemandCost, sink.UnitDollar},
+			})...)
+		}
+	}
+	return metrics, nil
+}
+
+// linkedAccountTags ... tags of a linked account, with its name if it is resolved
+func linkedAccountTags(service, linkedAccount string, names map[string]string, tagKey, tagVal string) []string {
+	tags := []string{
+		utility.CombineStrings([]string{"linked_account_id:", linkedAccount}),
+	}
+	if name, ok := names[linkedAccount]; ok {
+		tags = append(tags, utility.CombineStrings([]string{"linked_account_name:", name}))
+	}
+	return append(tags,
+		utility.CombineStrings([]string{tagKey, ":", tagVal}),
+		tagVal,
+		utility.CombineStrings([]string{"service:", service}),
+	)
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// 連結アカウントごとのメトリクスに連結アカウント ID と解決できたアカウント名のタグを付ける
+func TestCollectLinkedAccounts(t *testing.T) {
+	client := &mockCostexplorer{
+		riLinkedAccounts: []*awsapi.RILinkedAccountUtilization{
+			{LinkedAccount: "111111111111", Utilization: &awsapi.RIUtilization{UtilizationPercentage: 50, UnusedHours: 12}},
+		},
+		riLinkedCoverages: []*awsapi.RICoverage{
+			{LinkedAccount: "222222222222", CoverageHoursPercentage: 80, OnDemandHours: 4, OnDemandCost: 0.5},
+		},
+	}
+	names := map[string]string{
+		"111111111111": "production",
+	}
+
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	metrics, err := collectLinkedAccounts(client, names, "Amazon Redshift", Event{}, w, "account", "hoge")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// utilization は期間全体の集計になるので日毎に取得する
+	expected := []string{
+		"2020-03-01 aws.ri.linked_account.utilization linked_account_id:111111111111,linked_account_name:production,account:hoge,hoge,service:Amazon Redshift 50",
+		"2020-03-01 aws.ri.linked_account.unused_hours linked_account_id:111111111111,linked_account_name:production,account:hoge,hoge,service:Amazon Redshift 12",
+		"2020-03-02 aws.ri.linked_account.utilization linked_account_id:111111111111,linked_account_name:production,account:hoge,hoge,service:Amazon Redshift 50",
+		"2020-03-02 aws.ri.linked_account.unused_hours linked_account_id:111111111111,linked_account_name:production,account:hoge,hoge,service:Amazon Redshift 12",
+		"2020-03-01 aws.ri.linked_account.coverage linked_account_id:222222222222,account:hoge,hoge,service:Amazon Redshift 80",
+		"2020-03-01 aws.ri.linked_account.on_demand_hours linked_account_id:222222222222,account:hoge,hoge,service:Amazon Redshift 4",
+		"2020-03-01 aws.ri.linked_account.on_demand_cost linked_account_id:222222222222,account:hoge,hoge,service:Amazon Redshift 0.5",
+	}
+	actual := []string{}
+	for i, s := range summarize(metrics) {
+		actual = append(actual, metrics[i].Timestamp.Format(dateLayout)+" "+s)
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestCollectLinkedAccountsFailed(t *testing.T) {
+	client := &mockCostexplorer{Error: errors.New("error occured")}
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	if _, err := collectLinkedAccounts(client, nil, "Amazon Redshift", Event{}, w, "account", "hoge"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
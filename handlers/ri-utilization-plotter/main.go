@@ -3,144 +3,504 @@ package main
 import (
 	"context"
 	"fmt"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/costexplorer"
 	"github.com/pkg/errors"
 	"github.com/zorkian/go-datadog-api"
 
 	"github.com/kenzo0107/ri-utilization-plotter/configs"
 	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/cache"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
 	"github.com/kenzo0107/ri-utilization-plotter/pkg/utility"
 )
 
 var (
+	// services : services collected when the discovery is disabled
 	services = []string{
 		"Amazon Elastic Compute Cloud - Compute",
 		"Amazon Relational Database Service",
 		"Amazon ElastiCache",
 		"Amazon Redshift",
 		"Amazon Elasticsearch Service",
+		"Amazon OpenSearch Service",
 	}
-	unixTime      float64
-	startDay      string
-	endDay        string
+	// datadogClient : client generated with the secrets resolved by the first sink posting to Datadog, reset when cfg is loaded
 	datadogClient *datadog.Client
-	sess          *session.Session = configs.Session
+	// cfg : configuration loaded on the first successful invocation and reused by warm Lambdas
+	cfg *configs.Config
+	// configExpiry : time after which cfg is loaded again to pick up rotated secrets, never if zero
+	configExpiry time.Time
+
+	// loadConfig : load the configuration
+	loadConfig = configs.Load
+	// now : clock deciding the reporting window of each invocation
+	now = time.Now
+	// newCostexplorer : generate the Cost Explorer client of the account on each invocation
+	newCostexplorer = newAccountCostexplorer
+	// newOrganizations : generate the Organizations client of the account on each invocation
+	newOrganizations = newAccountOrganizations
+	// newSTS : generate the STS client of the account on each invocation
+	newSTS = newAccountSTS
 )
 
-func init() {
-	now := time.Now()
-	unixTime = float64(now.Unix())
-	endDay = now.Format("2006-01-02")
-	// GetReservationUtilization 呼び出し時に最低でも 2 日前を指定する必要がある
-	startDay = now.AddDate(0, 0, -2).Format("2006-01-02")
+func main() {
+	lambda.Start(handler)
+}
+
+func handler(ctx context.Context, event Event) error {
+	if cfg == nil || (!configExpiry.IsZero() && !now().Before(configExpiry)) {
+		c, err := loadConfig(ctx)
+		if err != nil {
+			return errors.Wrap(err, "failed to load the configuration")
+		}
+		cfg = c
+		configExpiry = now().Add(cfg.Envs.ConfigTTL)
+		datadogClient = nil
+	}
 
-	datadogClient = datadog.NewClient(
-		configs.Secrets.DatadogAPIKey,
-		configs.Secrets.DatadogAppKey,
-	)
+	if err := event.validate(); err != nil {
+		return errors.Wrap(err, "invalid event")
+	}
+	defaultStart, defaultEnd := reportingPeriod(now(), cfg.Envs.LookbackDays)
+	start, end := event.period(defaultStart, defaultEnd)
+
+	windows := []window{
+		{
+			start:       start,
+			end:         end,
+			granularity: event.granularity(cfg.Envs.Granularity),
+		},
+	}
+	if event.Backfill {
+		var err error
+		if windows, err = dailyWindows(start, end); err != nil {
+			return errors.Wrap(err, "on dailyWindows")
+		}
+	}
+
+	// a dry run collects every metric as usual and prints them without creating any sink
+	var metricSink sink.Sink
+	var err error
+	if event.DryRun || cfg.Envs.DryRun {
+		if metricSink, err = newDryRunSink(event.dryRunFormat(cfg.Envs.DryRunFormat)); err != nil {
+			return errors.Wrap(err, "on newDryRunSink")
+		}
+	} else if metricSink, err = newMetricSink(ctx, cfg.Envs.Sinks); err != nil {
+		return errors.Wrap(err, "on newMetricSink")
+	}
+
+	accounts, err := parseAccounts(cfg.Envs.Accounts, cfg.Envs.AssumeRoleName)
+	if err != nil {
+		return errors.Wrap(err, "on parseAccounts")
+	}
+
+	// a cache is generated on every invocation to count the requests saved by the run
+	responseCache, err := newResponseCache(cfg.Envs.CacheStore, cfg.Envs.CacheFinalizedDays)
+	if err != nil {
+		return errors.Wrap(err, "on newResponseCache")
+	}
+
+	// stop collecting early enough to post the collected metrics before the Lambda times out
+	collectCtx := ctx
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancel context.CancelFunc
+		collectCtx, cancel = context.WithDeadline(ctx, deadline.Add(-cfg.Envs.DeadlineMargin))
+		defer cancel()
+	}
+
+	// clients of every account share the limit of the request rate
+	options := []awsapi.CostexplorerOption{
+		awsapi.WithRateLimiter(awsapi.NewRateLimiter(cfg.Envs.CostExplorerRPS)),
+		awsapi.WithRetry(cfg.Envs.CostExplorerMaxRetries),
+		// retries stop at the deadline of the collection instead of sleeping past it
+		awsapi.WithContext(collectCtx),
+	}
+	if groupBy := cfg.File.CoverageGroupBy; len(groupBy) > 0 {
+		options = append(options, awsapi.WithCoverageGroupBy(groupBy...))
+	}
+	if event.LinkedAccount != "" {
+		options = append(options, awsapi.WithLinkedAccount(event.LinkedAccount))
+	}
+	// a failure of an account, a service or a sink does not stop the rest of the run
+	targets, errs := newTargets(collectCtx, accounts, event, windows, options, responseCache)
+
+	// metrics of every window are posted at once, so that sinks can send them in as few requests as possible
+	metrics, collectErrs := collect(collectCtx, targets, event, windows)
+	errs = append(errs, collectErrs...)
+	if err := responseCache.Err(); err != nil {
+		errs = append(errs, &runError{stage: stageCache, err: err})
+	}
+	metrics = append(metrics, runMetrics(errs, responseCache, cfg.Envs.TagKey, cfg.Envs.TagVal)...)
+
+	// the metrics are posted with the deadline of the Lambda, DEADLINE_MARGIN after that of the collection
+	if err := metricSink.Post(ctx, sink.Dedupe(metrics)); err != nil {
+		postErrs := postErrors(err)
+		for _, e := range postErrs {
+			// keys rotated or revoked since they were resolved are resolved again by the next invocation
+			if e.sink == sinkDatadog && sink.IsDatadogAuthError(e.err) {
+				configExpiry = now()
+			}
+		}
+		errs = append(errs, postErrs...)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
 }
 
-func main() {
-	lambda.Start(handler)
+// newTargets ... targets of the accounts, whose services and linked account names are resolved concurrently
+//
+// An account whose services cannot be discovered is excluded,
+// and the linked accounts are tagged without their names if they cannot be resolved.
+// The responses of the clients are cached by responseCache unless it is nil.
+func newTargets(ctx context.Context, accounts []account, event Event, windows []window, options []awsapi.CostexplorerOption, responseCache *cache.Cache) ([]target, runErrors) {
+	resolved := make([]*target, len(accounts))
+	accountErrs := make([]runErrors, len(accounts))
+	err := forEach(ctx, len(accounts), cfg.Envs.Concurrency, func(i int) error {
+		a := accounts[i]
+		t := target{account: a, client: newCostexplorer(a, options...)}
+		if responseCache != nil {
+			// the clients of the accounts return different data even for the same requests
+			id, err := cacheAccountID(a)
+			if err != nil {
+				// requests of the account are sent without the cache, since they cannot be told from the others
+				accountErrs[i] = append(accountErrs[i], &runError{stage: stageCache, account: a, err: err})
+			} else {
+				t.client = responseCache.Wrap(t.client, id, event.LinkedAccount, strings.Join(cfg.File.CoverageGroupBy, ","))
+			}
+		}
+		var err error
+		// services specified by the event need no discovery
+		if len(windows) > 0 && len(event.targetServices(nil)) == 0 {
+			discovery := window{start: windows[0].start, end: windows[len(windows)-1].end}
+			t.dataLagDays, err = latestAvailable(discovery, maxLagDays(event), func(w window) (err error) {
+				t.services, err = discoverServices(t.client, w.start, w.end)
+				return err
+			})
+			// no data yet, so that there are no services to collect
+			if awsapi.IsDataUnavailable(err) {
+				t.services, err = []string{}, nil
+			}
+			if err != nil {
+				accountErrs[i] = append(accountErrs[i], &runError{stage: stageDiscover, account: a, err: err})
+				return nil
+			}
+		}
+		if cfg.Envs.LinkedAccounts && cfg.Envs.ResolveAccountNames {
+			if t.linkedAccountNames, err = newOrganizations(a).ListAccountNames(); err != nil {
+				accountErrs[i] = append(accountErrs[i], &runError{stage: stageResolveNames, account: a, err: errors.Wrap(err, "on ListAccountNames")})
+			}
+		}
+		resolved[i] = &t
+		return nil
+	})
+
+	targets := []target{}
+	var errs runErrors
+	for i := range accounts {
+		if resolved[i] != nil {
+			targets = append(targets, *resolved[i])
+		}
+		errs = append(errs, accountErrs[i]...)
+	}
+	if err != nil {
+		errs = append(errs, &runError{stage: stageDiscover, err: err})
+	}
+	return targets, errs
+}
+
+// job : collection of a service, or Savings Plans if the service is empty, in a window of an account
+type job struct {
+	target  target
+	window  window
+	service string
+}
+
+// collect ... metrics of the windows collected from every account
+//
+// The services of each account are collected concurrently by CONCURRENCY workers.
+// Data points are plotted at the start of the time period of Cost Explorer,
+// so that collecting the same period again overwrites them.
+// The default period is shifted back by up to MAX_DATA_LAG_DAYS days while Cost Explorer has no data of it,
+// and the days shifted are reported as ri_plotter.data_lag_days of each account.
+// A failed service is reported and the others are collected,
+// and the metrics collected so far are returned if ctx is done before every service is collected.
+func collect(ctx context.Context, targets []target, event Event, windows []window) ([]sink.Metric, runErrors) {
+	jobs := []job{}
+	for _, w := range windows {
+		for _, t := range targets {
+			for _, service := range event.targetServices(t.services) {
+				jobs = append(jobs, job{target: t, window: w, service: service})
+			}
+			if cfg.Envs.SavingsPlans {
+				jobs = append(jobs, job{target: t, window: w})
+			}
+		}
+	}
+
+	results := make([][]sink.Metric, len(jobs))
+	lags := make([]int, len(jobs))
+	jobErrs := make([]*runError, len(jobs))
+	err := forEach(ctx, len(jobs), cfg.Envs.Concurrency, func(i int) error {
+		j := jobs[i]
+		var m []sink.Metric
+		var err error
+		lags[i], err = latestAvailable(j.window, maxLagDays(event), func(w window) (err error) {
+			shifted := j
+			shifted.window = w
+			m, err = collectJob(shifted, event)
+			return err
+		})
+		// no data yet is not a failure, which is reported by the lag instead
+		if awsapi.IsDataUnavailable(err) {
+			m, err = nil, nil
+		}
+		if err != nil {
+			service := j.service
+			if service == "" {
+				service = "Savings Plans"
+			}
+			jobErrs[i] = &runError{
+				stage:   stageCollect,
+				account: j.target.account,
+				period:  fmt.Sprintf("%s - %s", j.window.start, j.window.end),
+				service: service,
+				err:     err,
+			}
+			return nil
+		}
+		results[i] = withTags(m, j.target.tags())
+		return nil
+	})
+
+	metrics := []sink.Metric{}
+	var errs runErrors
+	for i := range jobs {
+		metrics = append(metrics, results[i]...)
+		if jobErrs[i] != nil {
+			errs = append(errs, jobErrs[i])
+		}
+	}
+	if err != nil {
+		errs = append(errs, &runError{stage: stageCollect, err: err})
+	}
+	if event.latest() {
+		for _, t := range targets {
+			lag := t.dataLagDays
+			for i, j := range jobs {
+				if j.target.account == t.account && lags[i] > lag {
+					lag = lags[i]
+				}
+			}
+			metrics = append(metrics, lagMetric(t.account, lag, cfg.Envs.TagKey, cfg.Envs.TagVal))
+		}
+	}
+	return renameMetrics(withTags(metrics, cfg.File.TagList()), cfg.File.MetricNames), errs
 }
 
-func handler(ctx context.Context) error {
-	costexplorerClient := awsapi.NewCostexplorer(costexplorer.New(sess))
+// maxLagDays ... days the window of the event may be shifted back while Cost Explorer has no data of it
+//
+// Only the default period is shifted, and the newest days of a period specified by the event are just left uncollected.
+func maxLagDays(event Event) int {
+	if !event.latest() {
+		return 0
+	}
+	return cfg.Envs.MaxDataLagDays
+}
 
-	for _, service := range services {
-		// RI Utilization
-		utilPct, errRIUtil := costexplorerClient.FetchRIUtilizationPercentage(service, startDay, endDay)
+// collectJob ... metrics of the service or Savings Plans collected with the Cost Explorer client of the account
+func collectJob(j job, event Event) ([]sink.Metric, error) {
+	tagKey, tagVal := cfg.Envs.TagKey, cfg.Envs.TagVal
+	if j.service == "" {
+		m, err := collectSavingsPlans(j.target.client, event, j.window, tagKey, tagVal)
+		if err != nil {
+			return nil, errors.Wrap(err, "on collectSavingsPlans")
+		}
+		return m, nil
+	}
+	return collectService(j.target, j.service, event, j.window)
+}
+
+// collectService ... metrics of the service in the window collected with the Cost Explorer client of an account
+func collectService(t target, service string, event Event, w window) ([]sink.Metric, error) {
+	costexplorerClient := t.client
+	tagKey, tagVal := cfg.Envs.TagKey, cfg.Envs.TagVal
+	metrics := []sink.Metric{}
+
+	// RI Utilization
+	if event.collects(metricTypeUtilization) {
+		utils, errRIUtil := costexplorerClient.FetchRIUtilization(service, w.start, w.end, w.granularity)
 		if errRIUtil != nil {
-			return errors.Wrap(
-				errRIUtil,
-				fmt.Sprintf("service: %s on costexplorerClient.FetchRIUtilizationPercentage", service),
-			)
+			return nil, errors.Wrap(errRIUtil, "on costexplorerClient.FetchRIUtilization")
 		}
 
-		if utilPct != "" {
-			// utilPct == "" means that you do not use the service
-			utilPercentage, _ := strconv.ParseFloat(utilPct, 64)
-			if err := postMetricRIUtil(service, utilPercentage, configs.Envs.TagKey, configs.Envs.TagVal); err != nil {
-				return errors.Wrap(err, "on postMetricRIUtil.")
+		// utils is empty if you do not use the service
+		for _, u := range utils {
+			metrics = append(metrics, riUtilMetrics(service, u, tagKey, tagVal)...)
+		}
+
+		// RI Utilization of each reservation
+		if cfg.Envs.RISubscriptions {
+			// the utilization of each reservation covers the whole period requested, so that it is requested for each time period
+			// to plot it at the same timestamps as the utilization of the same period
+			periods, err := w.periods()
+			if err != nil {
+				return nil, errors.Wrap(err, "on w.periods")
+			}
+			for _, p := range periods {
+				subscriptions, err := costexplorerClient.FetchRIUtilizationBySubscription(service, p.start, p.end)
+				if err != nil {
+					return nil, errors.Wrap(err, "on costexplorerClient.FetchRIUtilizationBySubscription")
+				}
+
+				for _, u := range subscriptions {
+					metrics = append(metrics, riSubscriptionMetrics(service, u, tagKey, tagVal)...)
+				}
 			}
 		}
+	}
 
-		// RI Coverage
-		coveragePcts, errRICov := costexplorerClient.FetchRICoveragePercentage(service, startDay, endDay)
+	// RI Coverage
+	if event.collects(metricTypeCoverage) {
+		coverages, errRICov := costexplorerClient.FetchRICoverage(service, w.start, w.end, w.granularity)
 		if errRICov != nil {
-			return errors.Wrap(
-				errRICov,
-				fmt.Sprintf("service: %s on costexplorerClient.FetchRICoveragePercentage", service),
-			)
+			return nil, errors.Wrap(errRICov, "on costexplorerClient.FetchRICoverage")
 
 		}
 
-		for _, g := range coveragePcts {
-			// post metric of RI coverage to Datadog
-			if err := postMetricRICoverage(service, g, configs.Envs.TagKey, configs.Envs.TagVal); err != nil {
-				return errors.Wrap(err, "on postMetricRICoverage.")
-			}
+		for _, c := range coverages {
+			metrics = append(metrics, riCoverageMetrics(service, c, tagKey, tagVal)...)
 		}
 	}
-	return nil
+
+	// RI Utilization and Coverage of each linked account
+	if cfg.Envs.LinkedAccounts {
+		m, err := collectLinkedAccounts(costexplorerClient, t.linkedAccountNames, service, event, w, tagKey, tagVal)
+		if err != nil {
+			return nil, errors.Wrap(err, "on collectLinkedAccounts")
+		}
+		metrics = append(metrics, m...)
+	}
+
+	return metrics, nil
 }
 
-// postMetricRIUtil : post metric of RI utilization to Datadog
-func postMetricRIUtil(service string, utilPercentage float64, tagKey, tagVal string) error {
-	metric := "aws.ri.utilization"
-	typeDatadog := "guage"
+// withTags ... metrics with the tags appended
+func withTags(metrics []sink.Metric, tags []string) []sink.Metric {
+	if len(tags) == 0 {
+		return metrics
+	}
+	tagged := make([]sink.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		m.Tags = append(append([]string{}, m.Tags...), tags...)
+		tagged = append(tagged, m)
+	}
+	return tagged
+}
+
+// renameMetrics ... metrics renamed by the names keyed by the default ones
+func renameMetrics(metrics []sink.Metric, names map[string]string) []sink.Metric {
+	if len(names) == 0 {
+		return metrics
+	}
+	renamed := make([]sink.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		if name, ok := names[m.Name]; ok {
+			m.Name = name
+		}
+		renamed = append(renamed, m)
+	}
+	return renamed
+}
 
+// riUtilMetrics : metrics of RI utilization aggregates
+func riUtilMetrics(service string, u *awsapi.RIUtilization, tagKey, tagVal string) []sink.Metric {
 	tags := []string{
 		utility.CombineStrings([]string{tagKey, ":", tagVal}),
 		tagVal,
 		utility.CombineStrings([]string{"service:", service}),
 	}
 
-	series := []datadog.Metric{
-		{
-			Metric: &metric,
-			Points: []datadog.DataPoint{
-				{&unixTime, &utilPercentage},
-			},
-			Type: &typeDatadog,
-			Host: &tagVal,
-			Tags: tags,
-		},
-	}
-	return datadogClient.PostMetrics(series)
+	return aggregateMetrics(tags, u.Start, []aggregate{
+		{"aws.ri.utilization", u.UtilizationPercentage, sink.UnitPercent},
+		{"aws.ri.purchased_hours", u.PurchasedHours, sink.UnitHour},
+		{"aws.ri.total_actual_hours", u.TotalActualHours, sink.UnitHour},
+		{"aws.ri.unused_hours", u.UnusedHours, sink.UnitHour},
+		{"aws.ri.on_demand_cost_of_ri_hours_used", u.OnDemandCostOfRIHoursUsed, sink.UnitDollar},
+		{"aws.ri.net_savings", u.NetRISavings, sink.UnitDollar},
+		{"aws.ri.total_potential_savings", u.TotalPotentialRISavings, sink.UnitDollar},
+		{"aws.ri.amortized_upfront_fee", u.AmortizedUpfrontFee, sink.UnitDollar},
+		{"aws.ri.amortized_recurring_fee", u.AmortizedRecurringFee, sink.UnitDollar},
+		{"aws.ri.total_amortized_fee", u.TotalAmortizedFee, sink.UnitDollar},
+	})
 }
 
-// postMetricRICoverage : post metric of RI utilization to Datadog
-func postMetricRICoverage(service string, g *costexplorer.ReservationCoverageGroup, tagKey, tagVal string) error {
-	metric := "aws.ri.coverage"
-	typeDatadog := "guage"
+// riSubscriptionMetrics : metrics of utilization and unused hours of a reservation
+func riSubscriptionMetrics(service string, u *awsapi.RISubscriptionUtilization, tagKey, tagVal string) []sink.Metric {
+	tags := []string{
+		utility.CombineStrings([]string{"subscription_id:", u.SubscriptionID}),
+		utility.CombineStrings([]string{"instance_type:", u.InstanceType}),
+		utility.CombineStrings([]string{"region:", u.Region}),
+		utility.CombineStrings([]string{tagKey, ":", tagVal}),
+		tagVal,
+		utility.CombineStrings([]string{"service:", service}),
+	}
 
-	// string to float64
-	pct, _ := strconv.ParseFloat(*g.Coverage.CoverageHours.CoverageHoursPercentage, 64)
+	return aggregateMetrics(tags, u.Utilization.Start, []aggregate{
+		{"aws.ri.subscription.utilization", u.Utilization.UtilizationPercentage, sink.UnitPercent},
+		{"aws.ri.subscription.unused_hours", u.Utilization.UnusedHours, sink.UnitHour},
+	})
+}
 
-	tags := []string{
-		utility.CombineStrings([]string{"instance_type:", *g.Attributes["instanceType"]}),
-		utility.CombineStrings([]string{"region:", *g.Attributes["region"]}),
+// riCoverageMetrics : metrics of RI coverage in hours, cost and normalized units of a group, tagged with its attributes
+func riCoverageMetrics(service string, c *awsapi.RICoverage, tagKey, tagVal string) []sink.Metric {
+	tags := append(attributeTags(c.Attributes),
 		utility.CombineStrings([]string{tagKey, ":", tagVal}),
 		tagVal,
 		utility.CombineStrings([]string{"service:", service}),
+	)
+
+	coverages := []aggregate{
+		{"aws.ri.coverage", c.CoverageHoursPercentage, sink.UnitPercent},
+		{"aws.ri.coverage.on_demand_hours", c.OnDemandHours, sink.UnitHour},
+		{"aws.ri.coverage.reserved_hours", c.ReservedHours, sink.UnitHour},
+		{"aws.ri.coverage.total_running_hours", c.TotalRunningHours, sink.UnitHour},
+		{"aws.ri.coverage.on_demand_cost", c.OnDemandCost, sink.UnitDollar},
+	}
+	if u := c.NormalizedUnits; u != nil {
+		coverages = append(coverages, []aggregate{
+			{"aws.ri.coverage.normalized_units", u.CoveragePercentage, sink.UnitPercent},
+			{"aws.ri.coverage.on_demand_normalized_units", u.OnDemand, sink.UnitNone},
+			{"aws.ri.coverage.reserved_normalized_units", u.Reserved, sink.UnitNone},
+			{"aws.ri.coverage.total_running_normalized_units", u.TotalRunning, sink.UnitNone},
+		}...)
 	}
 
-	series := []datadog.Metric{
-		{
-			Metric: &metric,
-			Points: []datadog.DataPoint{
-				{&unixTime, &pct},
-			},
-			Type: &typeDatadog,
-			Host: &tagVal,
-			Tags: tags,
-		},
+	return aggregateMetrics(tags, c.Start, coverages)
+}
+
+// aggregate : a value of Cost Explorer posted as a metric
+type aggregate struct {
+	name  string
+	value float64
+	unit  string
+}
+
+// aggregateMetrics ... metrics of the aggregates sharing the tags and the timestamp
+func aggregateMetrics(tags []string, timestamp time.Time, aggregates []aggregate) []sink.Metric {
+	metrics := make([]sink.Metric, 0, len(aggregates))
+	for _, a := range aggregates {
+		metrics = append(metrics, sink.Metric{
+			Name:      a.name,
+			Value:     a.value,
+			Timestamp: timestamp,
+			Tags:      tags,
+			Unit:      a.unit,
+		})
 	}
-	return datadogClient.PostMetrics(series)
+	return metrics
 }
@@ -2,17 +2,226 @@ package main
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/google/go-cmp/cmp"
 	"github.com/zorkian/go-datadog-api"
+
+	"github.com/kenzo0107/ri-utilization-plotter/configs"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/cache"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
 )
 
+// TestMain ... run the tests with the default configuration, without accessing AWS
+func TestMain(m *testing.M) {
+	envs, err := configs.LoadEnvs()
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg = &configs.Config{
+		Envs: envs,
+		Session: session.Must(session.NewSession(&aws.Config{
+			Region:      aws.String(endpoints.UsEast1RegionID),
+			Credentials: credentials.AnonymousCredentials,
+		})),
+	}
+	os.Exit(m.Run())
+}
+
+// mockCostexplorer : returns the same data for any period, stamped at the start of the period
+type mockCostexplorer struct {
+	reservedServices    []string
+	riUtil              *awsapi.RIUtilization
+	riSubscriptionUtils []*awsapi.RISubscriptionUtilization
+	riCoverages         []*awsapi.RICoverage
+	riLinkedAccounts    []*awsapi.RILinkedAccountUtilization
+	riLinkedCoverages   []*awsapi.RICoverage
+	spUtil              *awsapi.SavingsPlansUtilization
+	spDetails           []*awsapi.SavingsPlansUtilization
+	spCoverages         []*awsapi.SavingsPlansCoverage
+	Error               error
+
+	// mu : lock of the records below, which are appended by workers concurrently
+	mu sync.Mutex
+	// serviceErrors : errors of FetchRIUtilization and FetchRICoverage for each service instead of Error
+	serviceErrors map[string]error
+	// dataUntil : FetchRIUtilization and FetchRICoverage return DataUnavailableException for periods ending after it if set
+	dataUntil string
+
+	// periods : "start end granularity" queried by FetchRIUtilization and FetchRICoverage
+	periods []string
+	// discoveries : periods queried by FetchReservedServices
+	discoveries []string
+}
+
+// unavailable ... DataUnavailableException if the period ends after the data
+func (m *mockCostexplorer) unavailable(endDay string) error {
+	if m.dataUntil == "" || endDay <= m.dataUntil {
+		return nil
+	}
+	return awserr.New(costexplorer.ErrCodeDataUnavailableException, "Data is not available. Please try to adjust the time period.", nil)
+}
+
+func start(startDay string) time.Time {
+	t, _ := time.Parse(dateLayout, startDay)
+	return t
+}
+
+func (m *mockCostexplorer) FetchReservedServices(startDay, endDay string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.discoveries = append(m.discoveries, strings.Join([]string{startDay, endDay}, " "))
+	return m.reservedServices, m.Error
+}
+
+func (m *mockCostexplorer) FetchRIUtilization(service, startDay, endDay, granularity string) ([]*awsapi.RIUtilization, error) {
+	m.mu.Lock()
+	m.periods = append(m.periods, strings.Join([]string{startDay, endDay, granularity}, " "))
+	m.mu.Unlock()
+	if err := m.unavailable(endDay); err != nil {
+		return nil, err
+	}
+	if err, ok := m.serviceErrors[service]; ok {
+		return nil, err
+	}
+	if m.riUtil == nil {
+		return []*awsapi.RIUtilization{}, m.Error
+	}
+	u := *m.riUtil
+	u.Start = start(startDay)
+	return []*awsapi.RIUtilization{&u}, m.Error
+}
+
+func (m *mockCostexplorer) FetchRIUtilizationBySubscription(service, startDay, endDay string) ([]*awsapi.RISubscriptionUtilization, error) {
+	utils := []*awsapi.RISubscriptionUtilization{}
+	for _, s := range m.riSubscriptionUtils {
+		u := *s
+		util := *s.Utilization
+		util.Start = start(startDay)
+		u.Utilization = &util
+		utils = append(utils, &u)
+	}
+	return utils, m.Error
+}
+
+func (m *mockCostexplorer) FetchRICoverage(service, startDay, endDay, granularity string) ([]*awsapi.RICoverage, error) {
+	m.mu.Lock()
+	m.periods = append(m.periods, strings.Join([]string{startDay, endDay, granularity}, " "))
+	m.mu.Unlock()
+	if err := m.unavailable(endDay); err != nil {
+		return nil, err
+	}
+	if err, ok := m.serviceErrors[service]; ok {
+		return nil, err
+	}
+	coverages := []*awsapi.RICoverage{}
+	for _, c := range m.riCoverages {
+		cov := *c
+		cov.Start = start(startDay)
+		coverages = append(coverages, &cov)
+	}
+	return coverages, m.Error
+}
+
+func (m *mockCostexplorer) FetchRIUtilizationByLinkedAccount(service, startDay, endDay string) ([]*awsapi.RILinkedAccountUtilization, error) {
+	utils := []*awsapi.RILinkedAccountUtilization{}
+	for _, l := range m.riLinkedAccounts {
+		u := *l
+		util := *l.Utilization
+		util.Start = start(startDay)
+		u.Utilization = &util
+		utils = append(utils, &u)
+	}
+	return utils, m.Error
+}
+
+func (m *mockCostexplorer) FetchRICoverageByLinkedAccount(service, startDay, endDay, granularity string) ([]*awsapi.RICoverage, error) {
+	coverages := []*awsapi.RICoverage{}
+	for _, c := range m.riLinkedCoverages {
+		cov := *c
+		cov.Start = start(startDay)
+		coverages = append(coverages, &cov)
+	}
+	return coverages, m.Error
+}
+
+func (m *mockCostexplorer) FetchSavingsPlansUtilization(startDay, endDay, granularity string) ([]*awsapi.SavingsPlansUtilization, error) {
+	utils := []*awsapi.SavingsPlansUtilization{}
+	if m.spUtil == nil {
+		return utils, m.Error
+	}
+	// a utilization of each time period
+	periods, _ := window{start: startDay, end: endDay, granularity: granularity}.periods()
+	for _, p := range periods {
+		u := *m.spUtil
+		u.Start = start(p.start)
+		utils = append(utils, &u)
+	}
+	return utils, m.Error
+}
+
+func (m *mockCostexplorer) FetchSavingsPlansUtilizationDetails(startDay, endDay string) ([]*awsapi.SavingsPlansUtilization, error) {
+	details := []*awsapi.SavingsPlansUtilization{}
+	for _, d := range m.spDetails {
+		u := *d
+		u.Start = start(startDay)
+		details = append(details, &u)
+	}
+	return details, m.Error
+}
+
+func (m *mockCostexplorer) FetchSavingsPlansCoverage(startDay, endDay, granularity string) ([]*awsapi.SavingsPlansCoverage, error) {
+	coverages := []*awsapi.SavingsPlansCoverage{}
+	for _, c := range m.spCoverages {
+		cov := *c
+		cov.Start = start(startDay)
+		coverages = append(coverages, &cov)
+	}
+	return coverages, m.Error
+}
+
+// summarize ... metrics as "name tags value" to compare them regardless of the timestamp
+func summarize(metrics []sink.Metric) []string {
+	s := []string{}
+	for _, m := range metrics {
+		s = append(s, fmt.Sprintf("%s %s %v", m.Name, strings.Join(m.Tags, ","), m.Value))
+	}
+	return s
+}
+
 func TestHandler(t *testing.T) {
+	// datadog へ送信された series
+	var posted []datadog.Metric
+	record := func(r *http.Request) {
+		body := struct {
+			Series []datadog.Metric `json:"series"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		posted = append(posted, body.Series...)
+	}
+
 	// datadog のエンドポイントへメトリクスをプロットする際の必ず200ステータスを返す（成功する）テストサーバ
 	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
 		w.WriteHeader(200)
 	}))
 	defer ts.Close()
@@ -25,6 +234,7 @@ func TestHandler(t *testing.T) {
 
 	// datadog のエンドポイントへメトリクスをプロットする際の必ず 403 ステータスを返す（失敗する）テストサーバ
 	tsFailed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		record(r)
 		w.WriteHeader(403)
 	}))
 	defer tsFailed.Close()
@@ -35,63 +245,676 @@ func TestHandler(t *testing.T) {
 	}
 	ddClientFailed.SetBaseUrl(tsFailed.URL)
 
-	services = []string{
+	// RI を購入しているサービス
+	reserved := []string{
 		"Amazon Elastic Compute Cloud - Compute",
 		"Amazon Redshift",
 	}
 	type args struct {
-		ctx              context.Context
-		startDay, endDay string
-		datadogClient    *datadog.Client
+		ctx           context.Context
+		event         Event
+		costexplorer  *mockCostexplorer
+		datadogClient *datadog.Client
 	}
 
-	now := time.Now()
+	// Cost Explorer が利用実績を返す
+	used := &mockCostexplorer{
+		reservedServices: reserved,
+		riUtil:           &awsapi.RIUtilization{UtilizationPercentage: 100},
+		riCoverages: []*awsapi.RICoverage{
+			{Attributes: map[string]string{"region": "ap-northeast-1", "instanceType": "t3.nano"}, CoverageHoursPercentage: 50},
+		},
+	}
+
+	// Redshift の取得だけが失敗する
+	redshiftDenied := &mockCostexplorer{
+		reservedServices: reserved,
+		riUtil:           &awsapi.RIUtilization{UtilizationPercentage: 100},
+		serviceErrors:    map[string]error{"Amazon Redshift": errors.New("AccessDeniedException")},
+	}
 
 	ctx := context.Background()
 	tests := []struct {
 		name    string
 		args    args
 		wantErr bool
+		// stages : stages of the failures reported at the end of the run
+		stages []string
+		// services : services whose aws.ri.utilization is sent to datadog
+		services []string
 	}{
 		{
 			name: "successfully",
 			args: args{
 				ctx:           ctx,
-				startDay:      now.AddDate(0, 0, -3).Format("2006-01-02"),
-				endDay:        now.Format("2006-01-02"),
+				costexplorer:  used,
 				datadogClient: ddClient,
 			},
-			wantErr: false,
+			wantErr:  false,
+			services: reserved,
 		},
 		{
 			name: "start date cannot be after 2 days ago",
 			args: args{
-				ctx:           ctx,
-				startDay:      now.Format("2006-01-02"),
-				endDay:        now.Format("2006-01-02"),
+				ctx: ctx,
+				event: Event{
+					StartDay: time.Now().Format("2006-01-02"),
+				},
+				costexplorer: &mockCostexplorer{
+					Error: errors.New("ValidationException: Start date (and hour) should be before end date (and hour)"),
+				},
 				datadogClient: ddClient,
 			},
-			wantErr: true,
+			wantErr:  true,
+			stages:   []string{stageDiscover},
+			services: []string{},
+		},
+		{
+			// Cost Explorer にまだデータがなくても失敗しない
+			name: "no data yet",
+			args: args{
+				ctx: ctx,
+				costexplorer: &mockCostexplorer{
+					Error: awserr.New(costexplorer.ErrCodeDataUnavailableException, "Data is not available. Please try to adjust the time period.", nil),
+				},
+				datadogClient: ddClient,
+			},
+			wantErr:  false,
+			services: []string{},
 		},
 		{
+			// 送信に失敗しても全てのサービスを収集して送信を試みる
 			name: "failed to post metric to datadog",
 			args: args{
 				ctx:           ctx,
-				startDay:      now.AddDate(0, 0, -3).Format("2006-01-02"),
-				endDay:        now.Format("2006-01-02"),
+				costexplorer:  used,
 				datadogClient: ddClientFailed,
 			},
+			wantErr:  true,
+			stages:   []string{stagePost},
+			services: reserved,
+		},
+		{
+			// 失敗したサービス以外は送信する
+			name: "failed to collect a service",
+			args: args{
+				ctx:           ctx,
+				costexplorer:  redshiftDenied,
+				datadogClient: ddClient,
+			},
+			wantErr:  true,
+			stages:   []string{stageCollect},
+			services: []string{"Amazon Elastic Compute Cloud - Compute"},
+		},
+		{
+			name: "only coverage of the service specified by the event",
+			args: args{
+				ctx: ctx,
+				event: Event{
+					Service:      "Amazon Redshift",
+					StartDay:     time.Now().AddDate(0, 0, -3).Format("2006-01-02"),
+					EndDay:       time.Now().AddDate(0, 0, -2).Format("2006-01-02"),
+					CEMetricType: "coverage",
+				},
+				costexplorer:  used,
+				datadogClient: ddClient,
+			},
+			wantErr: false,
+		},
+		{
+			name: "invalid event",
+			args: args{
+				ctx:           ctx,
+				event:         Event{Granularity: "HOURLY"},
+				costexplorer:  used,
+				datadogClient: ddClient,
+			},
 			wantErr: true,
 		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			startDay = tt.args.startDay
-			endDay = tt.args.endDay
+			newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+				return tt.args.costexplorer
+			}
 			datadogClient = tt.args.datadogClient
-			if err := handler(tt.args.ctx); (err != nil) != tt.wantErr {
+			posted = nil
+			// 403 で期限切れになった設定を次のケースで読み込み直さない
+			defer func() { configExpiry = time.Time{} }()
+			err := handler(tt.args.ctx, tt.args.event)
+			if (err != nil) != tt.wantErr {
 				t.Errorf("handler() error = %v, wantErr %v", err, tt.wantErr)
 			}
+
+			if tt.stages != nil {
+				errs, ok := err.(runErrors)
+				if !ok {
+					t.Fatalf("wrong result : %v", err)
+				}
+				stages := []string{}
+				for _, e := range errs {
+					stages = append(stages, e.stage)
+				}
+				if diff := cmp.Diff(tt.stages, stages); diff != "" {
+					t.Errorf("wrong result : %s", diff)
+				}
+			}
+			if tt.services != nil {
+				services := []string{}
+				for _, m := range posted {
+					if *m.Metric != "aws.ri.utilization" {
+						continue
+					}
+					for _, tag := range m.Tags {
+						if strings.HasPrefix(tag, "service:") {
+							services = append(services, strings.TrimPrefix(tag, "service:"))
+						}
+					}
+				}
+				if diff := cmp.Diff(tt.services, services); diff != "" {
+					t.Errorf("wrong result : %s", diff)
+				}
+			}
 		})
 	}
 }
+
+// backfill の全ての期間のメトリクスを 1 度のリクエストで datadog へ送信する
+func TestHandlerPostsOnce(t *testing.T) {
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(202)
+	}))
+	defer ts.Close()
+	datadogClient = &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	datadogClient.SetBaseUrl(ts.URL)
+
+	client := &mockCostexplorer{
+		riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+		riCoverages: []*awsapi.RICoverage{
+			{Attributes: map[string]string{"region": "us-east-1", "instanceType": "dc2.large"}, CoverageHoursPercentage: 50},
+		},
+	}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return client
+	}
+
+	event := Event{Service: "Amazon Redshift", StartDay: "2020-03-01", EndDay: "2020-03-04", Backfill: true}
+	if err := handler(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+	if requests != 1 {
+		t.Errorf("wrong result : %d requests are sent", requests)
+	}
+}
+
+// 設定の読み込みに失敗したらプロセスを終了せずエラーを返し、成功したら以降の呼び出しで再利用する
+func TestHandlerLoadsConfig(t *testing.T) {
+	loaded := cfg
+	defer func() {
+		cfg = loaded
+		configExpiry = time.Time{}
+		loadConfig = configs.Load
+	}()
+
+	cfg = nil
+	loadConfig = func(context.Context) (*configs.Config, error) {
+		return nil, errors.New("open config.yaml: no such file or directory")
+	}
+	err := handler(context.Background(), Event{})
+	if err == nil {
+		t.Fatal("wrong result : err is nil")
+	}
+	if expected := "failed to load the configuration: open config.yaml: no such file or directory"; err.Error() != expected {
+		t.Errorf("wrong result : %s", err)
+	}
+
+	loads := 0
+	loadConfig = func(context.Context) (*configs.Config, error) {
+		loads++
+		return loaded, nil
+	}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return &mockCostexplorer{}
+	}
+	for i := 0; i < 2; i++ {
+		if err := handler(context.Background(), Event{Service: "Amazon Redshift", Granularity: "HOURLY"}); err == nil {
+			t.Error("wrong result : err is nil")
+		}
+	}
+	if loads != 1 {
+		t.Errorf("wrong result : loaded %d times", loads)
+	}
+}
+
+// 設定は CONFIG_TTL を過ぎるか datadog にキーを拒否されたら読み込み直す
+func TestHandlerReloadsConfig(t *testing.T) {
+	loaded := cfg
+	defer func() {
+		cfg = loaded
+		configExpiry = time.Time{}
+		loadConfig = configs.Load
+		now = time.Now
+	}()
+
+	loads := 0
+	loadConfig = func(context.Context) (*configs.Config, error) {
+		loads++
+		c := *loaded
+		c.Envs.ConfigTTL = time.Hour
+		return &c, nil
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(403)
+	}))
+	defer ts.Close()
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return &mockCostexplorer{riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100}}
+	}
+
+	start := time.Date(2020, 3, 3, 10, 0, 0, 0, time.UTC)
+	// 不正なイベントは設定を読み込んだ後に失敗し、メトリクスを送信しない
+	invalid := Event{Granularity: "HOURLY"}
+	invoke := func(at time.Time, event Event) {
+		now = func() time.Time { return at }
+		if err := handler(context.Background(), event); err == nil {
+			t.Error("wrong result : err is nil")
+		}
+	}
+
+	cfg = nil
+	invoke(start, invalid)
+	invoke(start.Add(59*time.Minute), invalid)
+	if loads != 1 {
+		t.Errorf("wrong result : loaded %d times before the TTL", loads)
+	}
+	invoke(start.Add(time.Hour), invalid)
+	if loads != 2 {
+		t.Errorf("wrong result : loaded %d times after the TTL", loads)
+	}
+
+	datadogClient = &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	datadogClient.SetBaseUrl(ts.URL)
+	invoke(start.Add(time.Hour+time.Minute), Event{Service: "Amazon Redshift"})
+	if loads != 2 {
+		t.Errorf("wrong result : loaded %d times before the keys are rejected", loads)
+	}
+	invoke(start.Add(time.Hour+2*time.Minute), invalid)
+	if loads != 3 {
+		t.Errorf("wrong result : loaded %d times after the keys are rejected", loads)
+	}
+}
+
+// warm な Lambda で再利用されても呼び出しごとに期間を計算し直す
+func TestHandlerRecomputesWindow(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+	datadogClient = &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	datadogClient.SetBaseUrl(ts.URL)
+
+	client := &mockCostexplorer{
+		reservedServices: []string{"Amazon Redshift"},
+	}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return client
+	}
+	defer func() { now = time.Now }()
+
+	for _, day := range []time.Time{
+		time.Date(2020, 3, 10, 10, 0, 0, 0, time.UTC),
+		time.Date(2020, 3, 11, 22, 0, 0, 0, time.UTC),
+	} {
+		day := day
+		now = func() time.Time { return day }
+		if err := handler(context.Background(), Event{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := []string{
+		// 1 度目の呼び出し
+		"2020-03-08 2020-03-10 DAILY",
+		"2020-03-08 2020-03-10 DAILY",
+		// 2 度目の呼び出し
+		"2020-03-09 2020-03-11 DAILY",
+		"2020-03-09 2020-03-11 DAILY",
+	}
+	if diff := cmp.Diff(expected, client.periods); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestReportingPeriod(t *testing.T) {
+	start, end := reportingPeriod(time.Date(2020, 3, 1, 10, 0, 0, 0, time.UTC), 7)
+	if diff := cmp.Diff([]string{"2020-02-23", "2020-03-01"}, []string{start, end}); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestRIUtilMetrics(t *testing.T) {
+	u := &awsapi.RIUtilization{
+		UtilizationPercentage:     75,
+		PurchasedHours:            48,
+		TotalActualHours:          36,
+		UnusedHours:               12,
+		OnDemandCostOfRIHoursUsed: 0.3,
+		NetRISavings:              0.1,
+		TotalPotentialRISavings:   0.2,
+		AmortizedUpfrontFee:       0.1,
+		AmortizedRecurringFee:     0.05,
+		TotalAmortizedFee:         0.15,
+	}
+
+	tags := "account:hoge,hoge,service:Amazon Redshift"
+	expected := []string{
+		"aws.ri.utilization " + tags + " 75",
+		"aws.ri.purchased_hours " + tags + " 48",
+		"aws.ri.total_actual_hours " + tags + " 36",
+		"aws.ri.unused_hours " + tags + " 12",
+		"aws.ri.on_demand_cost_of_ri_hours_used " + tags + " 0.3",
+		"aws.ri.net_savings " + tags + " 0.1",
+		"aws.ri.total_potential_savings " + tags + " 0.2",
+		"aws.ri.amortized_upfront_fee " + tags + " 0.1",
+		"aws.ri.amortized_recurring_fee " + tags + " 0.05",
+		"aws.ri.total_amortized_fee " + tags + " 0.15",
+	}
+	if diff := cmp.Diff(expected, summarize(riUtilMetrics("Amazon Redshift", u, "account", "hoge"))); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestRISubscriptionMetrics(t *testing.T) {
+	u := &awsapi.RISubscriptionUtilization{
+		SubscriptionID: "1234567890",
+		InstanceType:   "t3.nano",
+		Region:         "ap-northeast-1",
+		Utilization: &awsapi.RIUtilization{
+			UtilizationPercentage: 50,
+			UnusedHours:           48,
+		},
+	}
+
+	tags := "subscription_id:1234567890,instance_type:t3.nano,region:ap-northeast-1,account:hoge,hoge,service:Amazon Elastic Compute Cloud - Compute"
+	expected := []string{
+		"aws.ri.subscription.utilization " + tags + " 50",
+		"aws.ri.subscription.unused_hours " + tags + " 48",
+	}
+	if diff := cmp.Diff(expected, summarize(riSubscriptionMetrics("Amazon Elastic Compute Cloud - Compute", u, "account", "hoge"))); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestRICoverageMetrics(t *testing.T) {
+	tags := "instance_type:t3.nano,region:ap-northeast-1,account:hoge,hoge,service:Amazon Elastic Compute Cloud - Compute"
+
+	tests := []struct {
+		name     string
+		coverage *awsapi.RICoverage
+		expected []string
+	}{
+		{
+			name: "size flexible",
+			coverage: &awsapi.RICoverage{
+				Attributes: map[string]string{
+					"instanceType": "t3.nano",
+					"region":       "ap-northeast-1",
+				},
+				CoverageHoursPercentage: 50,
+				OnDemandHours:           24,
+				ReservedHours:           24,
+				TotalRunningHours:       48,
+				OnDemandCost:            0.16,
+				NormalizedUnits: &awsapi.RICoverageNormalizedUnits{
+					CoveragePercentage: 50,
+					OnDemand:           6,
+					Reserved:           6,
+					TotalRunning:       12,
+				},
+			},
+			expected: []string{
+				"aws.ri.coverage " + tags + " 50",
+				"aws.ri.coverage.on_demand_hours " + tags + " 24",
+				"aws.ri.coverage.reserved_hours " + tags + " 24",
+				"aws.ri.coverage.total_running_hours " + tags + " 48",
+				"aws.ri.coverage.on_demand_cost " + tags + " 0.16",
+				"aws.ri.coverage.normalized_units " + tags + " 50",
+				"aws.ri.coverage.on_demand_normalized_units " + tags + " 6",
+				"aws.ri.coverage.reserved_normalized_units " + tags + " 6",
+				"aws.ri.coverage.total_running_normalized_units " + tags + " 12",
+			},
+		},
+		{
+			name: "without normalized units",
+			coverage: &awsapi.RICoverage{
+				Attributes: map[string]string{
+					"instanceType": "t3.nano",
+					"region":       "ap-northeast-1",
+				},
+				CoverageHoursPercentage: 0,
+				OnDemandHours:           24,
+				TotalRunningHours:       24,
+				OnDemandCost:            0.16,
+			},
+			expected: []string{
+				"aws.ri.coverage " + tags + " 0",
+				"aws.ri.coverage.on_demand_hours " + tags + " 24",
+				"aws.ri.coverage.reserved_hours " + tags + " 0",
+				"aws.ri.coverage.total_running_hours " + tags + " 24",
+				"aws.ri.coverage.on_demand_cost " + tags + " 0.16",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual := summarize(riCoverageMetrics("Amazon Elastic Compute Cloud - Compute", tt.coverage, "account", "hoge"))
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// 収集したメトリクスは Cost Explorer の期間の開始時刻でプロットされる
+func TestCollect(t *testing.T) {
+	client := &mockCostexplorer{
+		riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+		riCoverages: []*awsapi.RICoverage{
+			{Attributes: map[string]string{"region": "us-east-1", "instanceType": "dc2.large"}, CoverageHoursPercentage: 50},
+		},
+	}
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	metrics, err := collect(context.Background(), ownAccount(client), Event{Services: []string{"Amazon Redshift", "Amazon ElastiCache"}, StartDay: w.start, EndDay: w.end}, []window{w})
+	if err != nil {
+		t.Error(err)
+	}
+
+	// utilization 10 aggregates + coverage 5 metrics for each service
+	if len(metrics) != 30 {
+		t.Errorf("wrong result : %d metrics", len(metrics))
+	}
+	for _, m := range metrics {
+		if !m.Timestamp.Equal(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)) {
+			t.Errorf("wrong timestamp : %v", m.Timestamp)
+		}
+	}
+}
+
+// 予約ごとの utilization は期間全体の集計になるので、日毎に取得して aws.ri.utilization と同じ時刻にプロットする
+func TestCollectServiceSubscriptions(t *testing.T) {
+	envs := cfg.Envs
+	defer func() { cfg.Envs = envs }()
+	cfg.Envs.RISubscriptions = true
+
+	client := &mockCostexplorer{
+		riSubscriptionUtils: []*awsapi.RISubscriptionUtilization{
+			{SubscriptionID: "1234567890", Utilization: &awsapi.RIUtilization{UtilizationPercentage: 50, UnusedHours: 12}},
+		},
+	}
+	metrics, err := collectService(target{client: client}, "Amazon Redshift", Event{CEMetricType: "utilization"}, testWindow)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tags := "subscription_id:1234567890,instance_type:,region:,account:yourproject,yourproject,service:Amazon Redshift"
+	expected := []string{
+		"2020-03-01 aws.ri.subscription.utilization " + tags + " 50",
+		"2020-03-01 aws.ri.subscription.unused_hours " + tags + " 12",
+		"2020-03-02 aws.ri.subscription.utilization " + tags + " 50",
+		"2020-03-02 aws.ri.subscription.unused_hours " + tags + " 12",
+	}
+	actual := []string{}
+	for i, s := range summarize(metrics) {
+		actual = append(actual, metrics[i].Timestamp.Format(dateLayout)+" "+s)
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 既定の期間にデータがなければ期間を遡って収集し、遡った日数を記録する
+func TestCollectDataLag(t *testing.T) {
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	tests := []struct {
+		name      string
+		dataUntil string
+		// timestamps : timestamps of the collected metrics
+		timestamps []time.Time
+		lag        float64
+	}{
+		{
+			name:       "data of the period",
+			dataUntil:  "2020-03-03",
+			timestamps: []time.Time{time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)},
+			lag:        0,
+		},
+		{
+			name:       "data until a day ago",
+			dataUntil:  "2020-03-02",
+			timestamps: []time.Time{time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC)},
+			lag:        1,
+		},
+		{
+			// MAX_DATA_LAG_DAYS 日遡ってもデータがない
+			name:       "no data",
+			dataUntil:  "2020-02-01",
+			timestamps: []time.Time{},
+			lag:        float64(cfg.Envs.MaxDataLagDays + 1),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCostexplorer{
+				riUtil:    &awsapi.RIUtilization{UtilizationPercentage: 100},
+				dataUntil: tt.dataUntil,
+			}
+			targets := []target{{account: account{id: "111111111111", alias: "production"}, client: client}}
+			metrics, errs := collect(context.Background(), targets, Event{Service: "Amazon Redshift"}, []window{w})
+			if errs != nil {
+				t.Fatal(errs)
+			}
+
+			timestamps := []time.Time{}
+			lags := []sink.Metric{}
+			for _, m := range metrics {
+				if m.Name == dataLagMetric {
+					lags = append(lags, m)
+					continue
+				}
+				if len(timestamps) == 0 || !timestamps[len(timestamps)-1].Equal(m.Timestamp) {
+					timestamps = append(timestamps, m.Timestamp)
+				}
+			}
+			if diff := cmp.Diff(tt.timestamps, timestamps); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+			if len(lags) != 1 {
+				t.Fatalf("wrong result : %d lags", len(lags))
+			}
+			if lags[0].Value != tt.lag {
+				t.Errorf("wrong result : %v", lags[0].Value)
+			}
+			expectedTags := []string{"account:yourproject", "yourproject", "account_id:111111111111", "account_alias:production"}
+			if diff := cmp.Diff(expectedTags, lags[0].Tags); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// 設定ファイルの静的なタグとメトリクス名を全てのメトリクスに適用する
+func TestCollectWithFileConfig(t *testing.T) {
+	file := cfg.File
+	defer func() { cfg.File = file }()
+	cfg.File = configs.FileConfig{
+		MetricNames: map[string]string{"aws.ri.utilization": "finops.ri.utilization"},
+		Tags:        map[string]string{"team": "finops"},
+	}
+
+	client := &mockCostexplorer{
+		riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+	}
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	metrics, err := collect(context.Background(), ownAccount(client), Event{Service: "Amazon Redshift", CEMetricType: "utilization"}, []window{w})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"finops.ri.utilization account:yourproject,yourproject,service:Amazon Redshift,team:finops 100",
+		"aws.ri.purchased_hours account:yourproject,yourproject,service:Amazon Redshift,team:finops 0",
+	}
+	if diff := cmp.Diff(expected, summarize(metrics)[:2]); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 送信する全てのメトリクスを設定ファイルの metric_names で名前を変えられる
+func TestMetricNamesKnown(t *testing.T) {
+	w := window{start: "2020-03-01", end: "2020-03-02", granularity: "DAILY"}
+	u := &awsapi.RIUtilization{}
+	c := &awsapi.RICoverage{NormalizedUnits: &awsapi.RICoverageNormalizedUnits{}}
+	linked, err := collectLinkedAccounts(&mockCostexplorer{
+		riLinkedAccounts:  []*awsapi.RILinkedAccountUtilization{{LinkedAccount: "111111111111", Utilization: u}},
+		riLinkedCoverages: []*awsapi.RICoverage{c},
+	}, nil, "Amazon Redshift", Event{}, w, "account", "yourproject")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	metrics := append(riUtilMetrics("Amazon Redshift", u, "account", "yourproject"), linked...)
+	metrics = append(metrics, riSubscriptionMetrics("Amazon Redshift", &awsapi.RISubscriptionUtilization{Utilization: u}, "account", "yourproject")...)
+	metrics = append(metrics, riCoverageMetrics("Amazon Redshift", c, "account", "yourproject")...)
+	metrics = append(metrics, spUtilMetric(&awsapi.SavingsPlansUtilization{}, "account", "yourproject"))
+	metrics = append(metrics, spDetailMetrics(&awsapi.SavingsPlansUtilization{}, "account", "yourproject")...)
+	metrics = append(metrics, spCoverageMetric(&awsapi.SavingsPlansCoverage{}, "account", "yourproject"))
+	metrics = append(metrics, lagMetric(account{}, 0, "account", "yourproject"))
+	metrics = append(metrics, runMetrics(nil, &cache.Cache{}, "account", "yourproject")...)
+
+	names := map[string]bool{}
+	for _, m := range metrics {
+		names[m.Name] = true
+	}
+	for _, name := range configs.MetricNames {
+		if !names[name] {
+			t.Errorf("wrong result : %s is not posted", name)
+		}
+		delete(names, name)
+	}
+	for name := range names {
+		t.Errorf("wrong result : %s is missing in configs.MetricNames", name)
+	}
+}
+
+// ownAccount ... the account of the Lambda collected with the client
+func ownAccount(client awsapi.CostexplorerIface) []target {
+	return []target{{client: client}}
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// forEach ... call fn with each index in [0, n) on at most workers goroutines
+//
+// No more calls are started once a call fails or ctx is done.
+// The error of the smallest failed index is returned, or the error of ctx if some indexes are not called.
+func forEach(ctx context.Context, n, workers int, fn func(i int) error) error {
+	if workers < 1 {
+		workers = 1
+	}
+	stop, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	errs := make([]error, n)
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < n; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				if errs[i] = fn(i); errs[i] != nil {
+					cancel()
+				}
+			}
+		}()
+	}
+
+	started := 0
+	for started < n && stop.Err() == nil {
+		select {
+		case indexes <- started:
+			started++
+		case <-stop.Done():
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+	if err := ctx.Err(); err != nil && started < n {
+		return errors.Wrap(err, fmt.Sprintf("stopped after %d of %d", started, n))
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// 同時に実行するのは workers 個までで、全ての index を 1 度ずつ処理する
+func TestForEach(t *testing.T) {
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	called := map[int]int{}
+
+	err := forEach(context.Background(), 10, 3, func(i int) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		called[i]++
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if maxRunning > 3 {
+		t.Errorf("wrong result : %d calls ran at once", maxRunning)
+	}
+	for i := 0; i < 10; i++ {
+		if called[i] != 1 {
+			t.Errorf("wrong result : index %d is called %d times", i, called[i])
+		}
+	}
+}
+
+// 失敗したら以降の index は処理しない
+func TestForEachFailed(t *testing.T) {
+	calls := 0
+	err := forEach(context.Background(), 10, 1, func(i int) error {
+		calls++
+		if i == 3 {
+			return errors.New("AccessDenied")
+		}
+		return nil
+	})
+	if err == nil || err.Error() != "AccessDenied" {
+		t.Errorf("wrong result : %v", err)
+	}
+	if calls >= 10 {
+		t.Errorf("wrong result : called %d times", calls)
+	}
+}
+
+// ctx が終了したら以降の index は処理せず ctx のエラーを返す
+func TestForEachCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	calls := 0
+	err := forEach(ctx, 10, 1, func(i int) error {
+		calls++
+		if i == 1 {
+			cancel()
+		}
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("wrong result : %v", err)
+	}
+	if calls >= 10 {
+		t.Errorf("wrong result : called %d times", calls)
+	}
+}
+
+// 期限までに収集したメトリクスを ctx のエラーとともに返す
+func TestCollectCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client := &cancelingCostexplorer{
+		mockCostexplorer: mockCostexplorer{riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100}},
+		cancel:           cancel,
+	}
+	concurrency := cfg.Envs.Concurrency
+	defer func() { cfg.Envs.Concurrency = concurrency }()
+	cfg.Envs.Concurrency = 1
+
+	services := []string{"Amazon Redshift", "Amazon ElastiCache", "Amazon Relational Database Service", "Amazon Elasticsearch Service"}
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	metrics, err := collect(ctx, ownAccount(client), Event{Services: services, CEMetricType: "utilization"}, []window{w})
+	if err == nil {
+		t.Fatal("wrong result : err is nil")
+	}
+	// 1 サービス目の utilization 10 aggregates は返す
+	if len(metrics) < 10 || len(metrics) >= 40 {
+		t.Errorf("wrong result : %d metrics", len(metrics))
+	}
+}
+
+// cancelingCostexplorer : cancel the context on the first request
+type cancelingCostexplorer struct {
+	mockCostexplorer
+	cancel context.CancelFunc
+}
+
+func (m *cancelingCostexplorer) FetchRIUtilization(service, startDay, endDay, granularity string) ([]*awsapi.RIUtilization, error) {
+	m.cancel()
+	return m.mockCostexplorer.FetchRIUtilization(service, startDay, endDay, granularity)
+}
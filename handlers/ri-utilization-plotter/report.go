@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/cache"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/utility"
+)
+
+// Stages of the run reported with the failures
+const (
+	stageDiscover     = "discover"
+	stageResolveNames = "resolve account names"
+	stageCollect      = "collect"
+	stagePost         = "post"
+	stageCache        = "cache"
+)
+
+const (
+	// runErrorsMetric : name of the metric of the failures of a run
+	runErrorsMetric = "ri_plotter.run.errors"
+	// dataLagMetric : name of the metric of the days Cost Explorer data is behind the default period
+	dataLagMetric = "ri_plotter.data_lag_days"
+	// cacheSavedMetric : name of the metric of the Cost Explorer requests answered by the cache in a run
+	cacheSavedMetric = "ri_plotter.cache.saved_calls"
+)
+
+// runError : failure of a stage, which does not stop the rest of the run
+type runError struct {
+	stage   string
+	account account
+	// period : "start - end" of the window, empty unless the failure is specific to a window
+	period string
+	// service : service, or Savings Plans, empty unless the failure is specific to it
+	service string
+	// sink : sink failing to post metrics, empty unless the stage is post
+	sink string
+	err  error
+}
+
+func (e *runError) Error() string {
+	parts := []string{e.stage}
+	if e.period != "" {
+		parts = append(parts, "period: "+e.period)
+	}
+	if e.account.id != "" {
+		parts = append(parts, fmt.Sprintf("account: %s (%s)", e.account.alias, e.account.id))
+	}
+	if e.service != "" {
+		parts = append(parts, "service: "+e.service)
+	}
+	if e.sink != "" {
+		parts = append(parts, "sink: "+e.sink)
+	}
+	return strings.Join(append(parts, e.err.Error()), ": ")
+}
+
+// runErrors : failures of a run reported at the end of the run
+type runErrors []*runError
+
+func (e runErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d errors occurred: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// postErrors ... failures of the sinks reported by sink.Multi
+func postErrors(err error) runErrors {
+	sinkErrs, ok := err.(sink.Errors)
+	if !ok {
+		return runErrors{{stage: stagePost, err: err}}
+	}
+	errs := runErrors{}
+	for _, e := range sinkErrs {
+		errs = append(errs, &runError{stage: stagePost, sink: e.Sink, err: e.Err})
+	}
+	return errs
+}
+
+// runMetrics ... metrics of the run itself, reported with the collected metrics
+//
+// ri_plotter.run.errors is the number of the failures before posting, 0 is reported as well to monitor the run.
+// ri_plotter.cache.saved_calls is the number of the requests answered by responseCache, reported unless it is nil.
+func runMetrics(errs runErrors, responseCache *cache.Cache, tagKey, tagVal string) []sink.Metric {
+	tags := []string{utility.CombineStrings([]string{tagKey, ":", tagVal}), tagVal}
+	metrics := []sink.Metric{
+		{
+			Name:      runErrorsMetric,
+			Value:     float64(len(errs)),
+			Timestamp: now(),
+			Tags:      tags,
+		},
+	}
+	if responseCache != nil {
+		metrics = append(metrics, sink.Metric{
+			Name:      cacheSavedMetric,
+			Value:     float64(responseCache.Saved()),
+			Timestamp: now(),
+			Tags:      tags,
+		})
+	}
+	return renameMetrics(withTags(metrics, cfg.File.TagList()), cfg.File.MetricNames)
+}
+
+// lagMetric ... metric of the days the default period of the account is shifted back to find data
+//
+// The lag is MAX_DATA_LAG_DAYS + 1 if no data is found, so that unusually late billing data can be alerted.
+func lagMetric(a account, lag int, tagKey, tagVal string) sink.Metric {
+	return sink.Metric{
+		Name:      dataLagMetric,
+		Value:     float64(lag),
+		Timestamp: now(),
+		Tags:      append([]string{utility.CombineStrings([]string{tagKey, ":", tagVal}), tagVal}, a.tags()...),
+		Unit:      sink.UnitDay,
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+)
+
+// 失敗したステージ、アカウント、期間、サービスと原因をまとめて報告する
+func TestRunErrors(t *testing.T) {
+	errs := runErrors{
+		{
+			stage:   stageCollect,
+			account: account{id: "111111111111", alias: "production"},
+			period:  "2020-03-01 - 2020-03-03",
+			service: "Amazon Redshift",
+			err:     errors.New("on costexplorerClient.FetchRIUtilization: AccessDeniedException"),
+		},
+		{stage: stageDiscover, err: errors.New("on costexplorerClient.FetchReservedServices: LimitExceededException")},
+		{stage: stagePost, sink: "datadog", err: errors.New("API error 403 Forbidden")},
+	}
+
+	expected := "3 errors occurred: " +
+		"collect: period: 2020-03-01 - 2020-03-03: account: production (111111111111): service: Amazon Redshift: on costexplorerClient.FetchRIUtilization: AccessDeniedException; " +
+		"discover: on costexplorerClient.FetchReservedServices: LimitExceededException; " +
+		"post: sink: datadog: API error 403 Forbidden"
+	if diff := cmp.Diff(expected, errs.Error()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// sink ごとの失敗を post ステージの失敗として報告する
+func TestPostErrors(t *testing.T) {
+	err := sink.NewMulti(
+		&failingSink{name: "datadog", err: errors.New("API error 403 Forbidden")},
+		&failingSink{name: "cloudwatch", err: errors.New("AccessDenied")},
+	).Post(context.Background(), []sink.Metric{})
+
+	actual := []string{}
+	for _, e := range postErrors(err) {
+		actual = append(actual, e.Error())
+	}
+	expected := []string{
+		"post: sink: datadog: API error 403 Forbidden",
+		"post: sink: cloudwatch: AccessDenied",
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 失敗の数を ri_plotter.run.errors として送信する、失敗がなくても 0 を送信する
+func TestRunMetrics(t *testing.T) {
+	defer func() { now = time.Now }()
+	now = func() time.Time { return time.Date(2020, 3, 3, 10, 0, 0, 0, time.UTC) }
+
+	for _, errs := range []runErrors{nil, {{stage: stageCollect, err: errors.New("AccessDeniedException")}}} {
+		expected := []sink.Metric{
+			{
+				Name:      "ri_plotter.run.errors",
+				Value:     float64(len(errs)),
+				Timestamp: time.Date(2020, 3, 3, 10, 0, 0, 0, time.UTC),
+				Tags:      []string{"account:hoge", "hoge"},
+			},
+		}
+		if diff := cmp.Diff(expected, runMetrics(errs, nil, "account", "hoge")); diff != "" {
+			t.Errorf("wrong result : %s", diff)
+		}
+	}
+}
+
+type failingSink struct {
+	name string
+	err  error
+}
+
+func (s *failingSink) Name() string {
+	return s.name
+}
+
+func (s *failingSink) Post(ctx context.Context, metrics []sink.Metric) error {
+	return s.err
+}
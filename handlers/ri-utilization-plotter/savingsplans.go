@@ -0,0 +1,129 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/utility"
+)
+
+// collectSavingsPlans ... metrics of Savings Plans utilization and coverage
+func collectSavingsPlans(costexplorerClient awsapi.CostexplorerIface, event Event, w window, tagKey, tagVal string) ([]sink.Metric, error) {
+	metrics := []sink.Metric{}
+
+	if event.collects(metricTypeUtilization) {
+		// utils is empty if you do not have any Savings Plans
+		utils, err := costexplorerClient.FetchSavingsPlansUtilization(w.start, w.end, w.granularity)
+		if err != nil {
+			return nil, errors.Wrap(err, "on costexplorerClient.FetchSavingsPlansUtilization")
+		}
+		for _, u := range utils {
+			metrics = append(metrics, spUtilMetric(u, tagKey, tagVal))
+		}
+
+		// the details cover the whole period requested, so that they are requested for each time period
+		// to plot them at the same timestamps as the utilization of the same period
+		periods, err := w.periods()
+		if err != nil {
+			return nil, errors.Wrap(err, "on w.periods")
+		}
+		for _, p := range periods {
+			details, err := costexplorerClient.FetchSavingsPlansUtilizationDetails(p.start, p.end)
+			if err != nil {
+				return nil, errors.Wrap(err, "on costexplorerClient.FetchSavingsPlansUtilizationDetails")
+			}
+			for _, d := range details {
+				metrics = append(metrics, spDetailMetrics(d, tagKey, tagVal)...)
+			}
+		}
+	}
+
+	if event.collects(metricTypeCoverage) {
+		coverages, err := costexplorerClient.FetchSavingsPlansCoverage(w.start, w.end, w.granularity)
+		if err != nil {
+			return nil, errors.Wrap(err, "on costexplorerClient.FetchSavingsPlansCoverage")
+		}
+		for _, c := range coverages {
+			metrics = append(metrics, spCoverageMetric(c, tagKey, tagVal))
+		}
+	}
+	return metrics, nil
+}
+
+// spUtilMetric : metric of Savings Plans utilization
+func spUtilMetric(u *awsapi.SavingsPlansUtilization, tagKey, tagVal string) sink.Metric {
+	return sink.Metric{
+		Name:      "aws.savingsplans.utilization",
+		Value:     u.UtilizationPercentage,
+		Timestamp: u.Start,
+		Tags: []string{
+			utility.CombineStrings([]string{tagKey, ":", tagVal}),
+			tagVal,
+		},
+		Unit: sink.UnitPercent,
+	}
+}
+
+// spDetailMetrics : metrics of utilization and unused commitment of a Savings Plan
+func spDetailMetrics(u *awsapi.SavingsPlansUtilization, tagKey, tagVal string) []sink.Metric {
+	tags := []string{
+		utility.CombineStrings([]string{"savings_plan_arn:", u.SavingsPlanArn}),
+		utility.CombineStrings([]string{tagKey, ":", tagVal}),
+		tagVal,
+	}
+
+	return aggregateMetrics(tags, u.Start, []aggregate{
+		{"aws.savingsplans.arn.utilization", u.UtilizationPercentage, sink.UnitPercent},
+		{"aws.savingsplans.arn.unused_commitment", u.UnusedCommitment, sink.UnitDollar},
+	})
+}
+
+// spCoverageMetric : metric of Savings Plans coverage
+func spCoverageMetric(c *awsapi.SavingsPlansCoverage, tagKey, tagVal string) sink.Metric {
+	tags := attributeTags(c.Attributes)
+	tags = append(tags,
+		utility.CombineStrings([]string{tagKey, ":", tagVal}),
+		tagVal,
+	)
+
+	return sink.Metric{
+		Name:      "aws.savingsplans.coverage",
+		Value:     c.CoveragePercentage,
+		Timestamp: c.Start,
+		Tags:      tags,
+		Unit:      sink.UnitPercent,
+	}
+}
+
+// attributeTags ... convert attributes of Cost Explorer into tags with snake case keys, e.g. instanceFamily:m5 to instance_family:m5
+func attributeTags(attributes map[string]string) []string {
+	keys := make([]string, 0, len(attributes))
+	for k := range attributes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tags := make([]string, 0, len(keys))
+	for _, k := range keys {
+		tags = append(tags, utility.CombineStrings([]string{snakeCase(k), ":", attributes[k]}))
+	}
+	return tags
+}
+
+// snakeCase ... instanceFamily, InstanceFamily and INSTANCE_FAMILY to instance_family
+func snakeCase(s string) string {
+	var b strings.Builder
+	runes := []rune(s)
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) && unicode.IsLower(runes[i-1]) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+var testWindow = window{
+	start:       "2020-03-01",
+	end:         "2020-03-03",
+	granularity: "DAILY",
+}
+
+func TestCollectSavingsPlans(t *testing.T) {
+	client := &mockCostexplorer{
+		spUtil: &awsapi.SavingsPlansUtilization{
+			UtilizationPercentage: 75,
+		},
+		spDetails: []*awsapi.SavingsPlansUtilization{
+			{
+				SavingsPlanArn:        "arn:aws:savingsplans::123456789012:savingsplan/a",
+				UtilizationPercentage: 75,
+				UnusedCommitment:      6,
+			},
+		},
+		spCoverages: []*awsapi.SavingsPlansCoverage{
+			{
+				Attributes:         map[string]string{"SERVICE": "AWS Lambda"},
+				CoveragePercentage: 40,
+			},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		event    Event
+		expected []string
+	}{
+		{
+			name:  "every metric type",
+			event: Event{},
+			// utilization と各プランの utilization は日毎に取得する
+			expected: []string{
+				"2020-03-01 aws.savingsplans.utilization account:hoge,hoge 75",
+				"2020-03-02 aws.savingsplans.utilization account:hoge,hoge 75",
+				"2020-03-01 aws.savingsplans.arn.utilization savings_plan_arn:arn:aws:savingsplans::123456789012:savingsplan/a,account:hoge,hoge 75",
+				"2020-03-01 aws.savingsplans.arn.unused_commitment savings_plan_arn:arn:aws:savingsplans::123456789012:savingsplan/a,account:hoge,hoge 6",
+				"2020-03-02 aws.savingsplans.arn.utilization savings_plan_arn:arn:aws:savingsplans::123456789012:savingsplan/a,account:hoge,hoge 75",
+				"2020-03-02 aws.savingsplans.arn.unused_commitment savings_plan_arn:arn:aws:savingsplans::123456789012:savingsplan/a,account:hoge,hoge 6",
+				"2020-03-01 aws.savingsplans.coverage service:AWS Lambda,account:hoge,hoge 40",
+			},
+		},
+		{
+			name:  "only coverage",
+			event: Event{CEMetricType: "coverage"},
+			expected: []string{
+				"2020-03-01 aws.savingsplans.coverage service:AWS Lambda,account:hoge,hoge 40",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metrics, err := collectSavingsPlans(client, tt.event, testWindow, "account", "hoge")
+			if err != nil {
+				t.Error(err)
+			}
+			actual := []string{}
+			for i, s := range summarize(metrics) {
+				actual = append(actual, metrics[i].Timestamp.Format(dateLayout)+" "+s)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+func TestCollectSavingsPlansFailed(t *testing.T) {
+	client := &mockCostexplorer{Error: errors.New("error occured")}
+	if _, err := collectSavingsPlans(client, Event{}, testWindow, "account", "hoge"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+func TestSnakeCase(t *testing.T) {
+	for _, s := range []string{"instanceFamily", "InstanceFamily", "INSTANCE_FAMILY"} {
+		if diff := cmp.Diff("instance_family", snakeCase(s)); diff != "" {
+			t.Errorf("%s wrong result : %s", s, diff)
+		}
+	}
+}
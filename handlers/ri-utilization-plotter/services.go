@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// discoverServices ... services to collect for the period, discovered once per run
+//
+// Services with reservations are discovered through Cost Explorer unless the discovery is disabled,
+// in which case the static list is used instead. The allow list is always collected and the deny list never.
+func discoverServices(costexplorerClient awsapi.CostexplorerIface, start, end string) ([]string, error) {
+	base := services
+	if cfg.Envs.DiscoverServices {
+		discovered, err := costexplorerClient.FetchReservedServices(start, end)
+		if err != nil {
+			return nil, errors.Wrap(err, "on costexplorerClient.FetchReservedServices")
+		}
+		base = discovered
+	}
+	return mergeServices(base, cfg.Envs.ServicesAllow, cfg.Envs.ServicesDeny), nil
+}
+
+// mergeServices ... services of base and allow without duplicates, excluding deny
+func mergeServices(base, allow, deny []string) []string {
+	excluded := map[string]bool{}
+	for _, s := range deny {
+		excluded[s] = true
+	}
+
+	merged := []string{}
+	for _, s := range append(append([]string{}, base...), allow...) {
+		if s == "" || excluded[s] {
+			continue
+		}
+		excluded[s] = true
+		merged = append(merged, s)
+	}
+	return merged
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/zorkian/go-datadog-api"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+func TestMergeServices(t *testing.T) {
+	base := []string{"Amazon Redshift", "Amazon ElastiCache", "Amazon Elasticsearch Service"}
+	allow := []string{"Amazon MemoryDB", "Amazon Redshift", ""}
+	deny := []string{"Amazon Elasticsearch Service"}
+
+	expected := []string{"Amazon Redshift", "Amazon ElastiCache", "Amazon MemoryDB"}
+	if diff := cmp.Diff(expected, mergeServices(base, allow, deny)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestDiscoverServices(t *testing.T) {
+	envs := cfg.Envs
+	defer func() { cfg.Envs = envs }()
+	cfg.Envs.ServicesAllow = []string{"Amazon ElastiCache"}
+	cfg.Envs.ServicesDeny = []string{"Amazon Relational Database Service"}
+
+	client := &mockCostexplorer{
+		reservedServices: []string{"Amazon OpenSearch Service", "Amazon Relational Database Service"},
+	}
+
+	tests := []struct {
+		name     string
+		discover bool
+		expected []string
+	}{
+		{
+			// Cost Explorer で見つかったサービスと allow のサービス
+			name:     "discovered services",
+			discover: true,
+			expected: []string{"Amazon OpenSearch Service", "Amazon ElastiCache"},
+		},
+		{
+			// 探索しない場合は固定のサービス一覧
+			name:     "static services",
+			discover: false,
+			expected: []string{
+				"Amazon Elastic Compute Cloud - Compute",
+				"Amazon ElastiCache",
+				"Amazon Redshift",
+				"Amazon Elasticsearch Service",
+				"Amazon OpenSearch Service",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.Envs.DiscoverServices = tt.discover
+			actual, err := discoverServices(client, "2020-03-01", "2020-03-03")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+func TestDiscoverServicesFailed(t *testing.T) {
+	envs := cfg.Envs
+	defer func() { cfg.Envs = envs }()
+	cfg.Envs.DiscoverServices = true
+
+	if _, err := discoverServices(&mockCostexplorer{Error: errors.New("error occured")}, "2020-03-01", "2020-03-03"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+// 日毎に backfill してもサービスの探索は実行ごとに全期間で 1 度だけ
+func TestHandlerDiscoversServicesOnce(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(200)
+	}))
+	defer ts.Close()
+	datadogClient = &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	datadogClient.SetBaseUrl(ts.URL)
+
+	client := &mockCostexplorer{
+		reservedServices: []string{"Amazon Redshift"},
+	}
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return client
+	}
+
+	event := Event{StartDay: "2020-03-01", EndDay: "2020-03-04", Backfill: true}
+	if err := handler(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	if diff := cmp.Diff([]string{"2020-03-01 2020-03-04"}, client.discoveries); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	// RI Utilization と Coverage を 3 日分
+	if len(client.periods) != 6 {
+		t.Errorf("wrong result : %v", client.periods)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/pkg/errors"
+	"github.com/zorkian/go-datadog-api"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/sink"
+)
+
+const (
+	sinkDatadog    = "datadog"
+	sinkCloudWatch = "cloudwatch"
+)
+
+// stdout : output of the metrics of a dry run, which goes to CloudWatch Logs on Lambda
+var stdout io.Writer = os.Stdout
+
+// newMetricSink ... generate a sink fanning out metrics to the sinks enabled by configuration
+//
+// The secrets are resolved only when the datadog sink is generated without datadogClient.
+func newMetricSink(ctx context.Context, names []string) (sink.Sink, error) {
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no sink is enabled")
+	}
+
+	sinks := []sink.Sink{}
+	for _, name := range names {
+		switch name {
+		case sinkDatadog:
+			if datadogClient == nil {
+				secrets, err := cfg.ResolveSecrets(ctx)
+				if err != nil {
+					return nil, errors.Wrap(err, "on cfg.ResolveSecrets")
+				}
+				datadogClient = datadog.NewClient(secrets.DatadogAPIKey, secrets.DatadogAppKey)
+			}
+			sinks = append(sinks, sink.NewDatadog(datadogClient, cfg.Envs.TagVal))
+		case sinkCloudWatch:
+			sinks = append(sinks, sink.NewCloudWatch(
+				awsapi.NewCloudWatch(cloudwatch.New(cfg.Session)),
+				cfg.Envs.CloudWatchNamespace,
+			))
+		default:
+			return nil, fmt.Errorf("unsupported sink %q", name)
+		}
+	}
+	return sink.NewMulti(sinks...), nil
+}
+
+// newDryRunSink ... generate a sink printing metrics to stdout in the format, which posts nothing
+func newDryRunSink(format string) (sink.Sink, error) {
+	return sink.NewWriter(stdout, format)
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/configs"
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// mockSecretProvider : provider of the secrets counting the calls
+type mockSecretProvider struct {
+	secrets configs.SecretParameters
+	err     error
+	calls   int
+}
+
+func (m *mockSecretProvider) Secrets(ctx context.Context) (configs.SecretParameters, error) {
+	m.calls++
+	return m.secrets, m.err
+}
+
+func TestNewMetricSink(t *testing.T) {
+	provider := cfg.SecretProvider
+	defer func() {
+		cfg.SecretProvider = provider
+		datadogClient = nil
+	}()
+	secrets := configs.SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"}
+
+	tests := []struct {
+		name     string
+		names    []string
+		provider *mockSecretProvider
+		expected string
+		// calls : number of the calls of the secret provider
+		calls   int
+		wantErr bool
+	}{
+		{
+			name:     "datadog",
+			names:    []string{"datadog"},
+			provider: &mockSecretProvider{secrets: secrets},
+			expected: "datadog",
+			calls:    1,
+		},
+		{
+			name:     "datadog and cloudwatch",
+			names:    []string{"datadog", "cloudwatch"},
+			provider: &mockSecretProvider{secrets: secrets},
+			expected: "datadog,cloudwatch",
+			calls:    1,
+		},
+		{
+			// datadog に送信しなければ secret は取得しない
+			name:     "cloudwatch",
+			names:    []string{"cloudwatch"},
+			provider: &mockSecretProvider{err: errors.New("AccessDenied")},
+			expected: "cloudwatch",
+		},
+		{
+			name:     "secrets unavailable",
+			names:    []string{"datadog"},
+			provider: &mockSecretProvider{err: errors.New("AccessDenied")},
+			calls:    1,
+			wantErr:  true,
+		},
+		{
+			name:     "unsupported sink",
+			names:    []string{"datadog", "prometheus"},
+			provider: &mockSecretProvider{secrets: secrets},
+			calls:    1,
+			wantErr:  true,
+		},
+		{
+			name:     "no sink",
+			names:    []string{},
+			provider: &mockSecretProvider{secrets: secrets},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg.SecretProvider = tt.provider
+			datadogClient = nil
+			s, err := newMetricSink(context.Background(), tt.names)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newMetricSink() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.provider.calls != tt.calls {
+				t.Errorf("wrong result : secrets are resolved %d times", tt.provider.calls)
+			}
+			if err != nil {
+				return
+			}
+			if diff := cmp.Diff(tt.expected, s.Name()); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// datadog の client を生成済みなら secret を取得し直さない
+func TestNewMetricSinkReusesClient(t *testing.T) {
+	provider := cfg.SecretProvider
+	defer func() {
+		cfg.SecretProvider = provider
+		datadogClient = nil
+	}()
+	p := &mockSecretProvider{secrets: configs.SecretParameters{DatadogAPIKey: "hogehoge", DatadogAppKey: "mogemoge"}}
+	cfg.SecretProvider = p
+	datadogClient = nil
+
+	for i := 0; i < 2; i++ {
+		if _, err := newMetricSink(context.Background(), []string{"datadog"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if p.calls != 1 {
+		t.Errorf("wrong result : secrets are resolved %d times", p.calls)
+	}
+}
+
+// dry run では収集したメトリクスを標準出力へ書き出し、datadog の secret を取得せず datadog へも送信しない
+func TestHandlerDryRun(t *testing.T) {
+	provider := cfg.SecretProvider
+	defer func() { cfg.SecretProvider = provider }()
+	p := &mockSecretProvider{err: errors.New("AccessDenied")}
+	cfg.SecretProvider = p
+	datadogClient = nil
+
+	newCostexplorer = func(account, ...awsapi.CostexplorerOption) awsapi.CostexplorerIface {
+		return &mockCostexplorer{
+			riUtil: &awsapi.RIUtilization{UtilizationPercentage: 100},
+		}
+	}
+	defer func(w io.Writer) { stdout = w }(stdout)
+
+	// 期待するメトリクスの名前
+	expected := []string{
+		"aws.ri.utilization",
+		"aws.ri.purchased_hours",
+		"aws.ri.total_actual_hours",
+		"aws.ri.unused_hours",
+		"aws.ri.on_demand_cost_of_ri_hours_used",
+		"aws.ri.net_savings",
+		"aws.ri.total_potential_savings",
+		"aws.ri.amortized_upfront_fee",
+		"aws.ri.amortized_recurring_fee",
+		"aws.ri.total_amortized_fee",
+		runErrorsMetric,
+	}
+
+	t.Run("table", func(t *testing.T) {
+		var b bytes.Buffer
+		stdout = &b
+		event := Event{Service: "Amazon Redshift", StartDay: "2020-03-01", EndDay: "2020-03-02", CEMetricType: "utilization", DryRun: true}
+		if err := handler(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if !strings.HasPrefix(lines[0], "METRIC") {
+			t.Errorf("wrong result : %s", lines[0])
+		}
+		names := []string{}
+		for _, l := range lines[1:] {
+			names = append(names, strings.Fields(l)[0])
+		}
+		if diff := cmp.Diff(expected, names); diff != "" {
+			t.Errorf("wrong result : %s", diff)
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var b bytes.Buffer
+		stdout = &b
+		event := Event{Service: "Amazon Redshift", StartDay: "2020-03-01", EndDay: "2020-03-02", CEMetricType: "utilization", DryRun: true, DryRunFormat: "json"}
+		if err := handler(context.Background(), event); err != nil {
+			t.Fatal(err)
+		}
+
+		names := []string{}
+		decoder := json.NewDecoder(&b)
+		for decoder.More() {
+			m := struct {
+				Metric string `json:"metric"`
+			}{}
+			if err := decoder.Decode(&m); err != nil {
+				t.Fatal(err)
+			}
+			names = append(names, m.Metric)
+		}
+		if diff := cmp.Diff(expected, names); diff != "" {
+			t.Errorf("wrong result : %s", diff)
+		}
+	})
+
+	if p.calls != 0 {
+		t.Errorf("wrong result : secrets are resolved %d times", p.calls)
+	}
+	if datadogClient != nil {
+		t.Error("wrong result : datadog client is generated")
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// window : period of Cost Explorer data
+type window struct {
+	start       string
+	end         string
+	granularity string
+}
+
+// reportingPeriod ... the period from lookbackDays days ago to today
+func reportingPeriod(now time.Time, lookbackDays int) (start, end string) {
+	// Cost Explorer は開始日が終了日より前であれば受け付ける (LOOKBACK_DAYS >= 1)
+	// 直近のデータがまだ無い場合は latestAvailable で期間を遡る
+	return now.AddDate(0, 0, -lookbackDays).Format(dateLayout), now.Format(dateLayout)
+}
+
+// dailyWindows ... windows of each day from start to end (exclusive)
+func dailyWindows(start, end string) ([]window, error) {
+	return window{start: start, end: end, granularity: granularityDaily}.periods()
+}
+
+// periods ... time periods of Cost Explorer in the window, each day or calendar month in the window by the granularity
+func (w window) periods() ([]window, error) {
+	periods, err := awsapi.Periods(w.start, w.end, w.granularity)
+	if err != nil {
+		return nil, err
+	}
+	windows := make([]window, 0, len(periods))
+	for _, p := range periods {
+		windows = append(windows, window{
+			start:       p.Start,
+			end:         p.End,
+			granularity: w.granularity,
+		})
+	}
+	return windows, nil
+}
+
+// previousDay ... the window shifted back by a day
+func (w window) previousDay() (window, error) {
+	s, err := time.Parse(dateLayout, w.start)
+	if err != nil {
+		return w, errors.Wrap(err, "on time.Parse of start")
+	}
+	e, err := time.Parse(dateLayout, w.end)
+	if err != nil {
+		return w, errors.Wrap(err, "on time.Parse of end")
+	}
+	w.start = s.AddDate(0, 0, -1).Format(dateLayout)
+	w.end = e.AddDate(0, 0, -1).Format(dateLayout)
+	return w, nil
+}
+
+// latestAvailable ... call fn with the window shifted back a day at a time while Cost Explorer has no data of it
+//
+// It returns the days shifted, which is maxLagDays + 1 with DataUnavailableException
+// if there is no data even in the window shifted back by maxLagDays.
+func latestAvailable(w window, maxLagDays int, fn func(w window) error) (int, error) {
+	for lag := 0; ; lag++ {
+		err := fn(w)
+		if !awsapi.IsDataUnavailable(err) {
+			return lag, err
+		}
+		if lag >= maxLagDays {
+			return lag + 1, err
+		}
+		if w, err = w.previousDay(); err != nil {
+			return lag, err
+		}
+	}
+}
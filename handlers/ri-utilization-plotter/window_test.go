@@ -0,0 +1,191 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+func TestDailyWindows(t *testing.T) {
+	windows, err := dailyWindows("2020-02-28", "2020-03-02")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []window{
+		{"2020-02-28", "2020-02-29", "DAILY"},
+		{"2020-02-29", "2020-03-01", "DAILY"},
+		{"2020-03-01", "2020-03-02", "DAILY"},
+	}
+	if diff := cmp.Diff(expected, windows, cmp.AllowUnexported(window{})); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestDailyWindowsFailed(t *testing.T) {
+	if _, err := dailyWindows("2020/02/28", "2020-03-02"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+	if _, err := dailyWindows("2020-02-28", "2020/03/02"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+// 日毎の window ごとにその日の開始時刻でメトリクスをプロットする
+func TestCollectBackfill(t *testing.T) {
+	windows, err := dailyWindows("2020-02-28", "2020-03-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, w := range windows {
+		metrics, err := collect(context.Background(), ownAccount(&mockCostexplorer{}), Event{Service: "Amazon Redshift", StartDay: "2020-02-28", Backfill: true}, []window{w})
+		if err != nil {
+			t.Error(err)
+		}
+		if len(metrics) != 0 {
+			t.Errorf("wrong result : %d metrics are collected without data", len(metrics))
+		}
+	}
+
+	client := &mockCostexplorer{
+		riCoverages: []*awsapi.RICoverage{
+			{Attributes: map[string]string{"region": "us-east-1", "instanceType": "dc2.large"}, CoverageHoursPercentage: 100},
+		},
+	}
+	timestamps := map[time.Time]bool{}
+	for _, w := range windows {
+		metrics, err := collect(context.Background(), ownAccount(client), Event{Service: "Amazon Redshift", CEMetricType: "coverage", StartDay: "2020-02-28", Backfill: true}, []window{w})
+		if err != nil {
+			t.Error(err)
+		}
+		for _, m := range metrics {
+			timestamps[m.Timestamp] = true
+		}
+	}
+	expected := map[time.Time]bool{
+		time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC): true,
+		time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC): true,
+	}
+	if diff := cmp.Diff(expected, timestamps); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestWindowPeriods(t *testing.T) {
+	tests := []struct {
+		name     string
+		window   window
+		expected []window
+	}{
+		{
+			name:   "daily",
+			window: window{"2020-02-28", "2020-03-01", "DAILY"},
+			expected: []window{
+				{"2020-02-28", "2020-02-29", "DAILY"},
+				{"2020-02-29", "2020-03-01", "DAILY"},
+			},
+		},
+		{
+			// 月の途中で始まり終わる期間はその範囲で区切る
+			name:   "monthly",
+			window: window{"2020-01-15", "2020-03-10", "MONTHLY"},
+			expected: []window{
+				{"2020-01-15", "2020-02-01", "MONTHLY"},
+				{"2020-02-01", "2020-03-01", "MONTHLY"},
+				{"2020-03-01", "2020-03-10", "MONTHLY"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.window.periods()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.expected, actual, cmp.AllowUnexported(window{})); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+func TestLatestAvailable(t *testing.T) {
+	unavailable := awserr.New(costexplorer.ErrCodeDataUnavailableException, "Data is not available. Please try to adjust the time period.", nil)
+	w := window{start: "2020-03-01", end: "2020-03-03", granularity: "DAILY"}
+	tests := []struct {
+		name string
+		// dataUntil : end of the data, the period ending after it fails with err
+		dataUntil  string
+		err        error
+		maxLagDays int
+		lag        int
+		// periods : "start end" of the periods queried
+		periods []string
+		wantErr bool
+	}{
+		{
+			name:       "data of the window",
+			dataUntil:  "2020-03-03",
+			maxLagDays: 3,
+			lag:        0,
+			periods:    []string{"2020-03-01 2020-03-03"},
+		},
+		{
+			name:       "shifted back to the data",
+			dataUntil:  "2020-03-01",
+			err:        unavailable,
+			maxLagDays: 3,
+			lag:        2,
+			periods:    []string{"2020-03-01 2020-03-03", "2020-02-29 2020-03-02", "2020-02-28 2020-03-01"},
+		},
+		{
+			// 上限まで遡ってもデータがない
+			name:       "no data within the limit",
+			dataUntil:  "2020-02-01",
+			err:        unavailable,
+			maxLagDays: 1,
+			lag:        2,
+			periods:    []string{"2020-03-01 2020-03-03", "2020-02-29 2020-03-02"},
+			wantErr:    true,
+		},
+		{
+			// データがないこと以外のエラーでは遡らない
+			name:       "other error",
+			dataUntil:  "2020-02-01",
+			err:        errors.New("AccessDeniedException"),
+			maxLagDays: 3,
+			lag:        0,
+			periods:    []string{"2020-03-01 2020-03-03"},
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			periods := []string{}
+			lag, err := latestAvailable(w, tt.maxLagDays, func(w window) error {
+				periods = append(periods, w.start+" "+w.end)
+				if w.end > tt.dataUntil {
+					return tt.err
+				}
+				return nil
+			})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("latestAvailable() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if lag != tt.lag {
+				t.Errorf("wrong result : %d", lag)
+			}
+			if diff := cmp.Diff(tt.periods, periods); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package awsapi
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+)
+
+// maxMetricDataPerRequest : PutMetricData accepts no more than 20 different metrics per request
+const maxMetricDataPerRequest = 20
+
+// CloudWatchIface : cloudwatch interface
+type CloudWatchIface interface {
+	PutMetricData(ctx context.Context, namespace string, data []*cloudwatch.MetricDatum) error
+}
+
+// CloudWatchInstance : cloudwatch instance
+type CloudWatchInstance struct {
+	client cloudwatchiface.CloudWatchAPI
+}
+
+// NewCloudWatch ... generate new cloudwatch client
+func NewCloudWatch(client cloudwatchiface.CloudWatchAPI) CloudWatchIface {
+	return &CloudWatchInstance{
+		client: client,
+	}
+}
+
+// PutErrors : failures of the requests of PutMetricData, while the other requests are sent
+type PutErrors struct {
+	Errs []error
+	// Requests : number of the requests of the put
+	Requests int
+}
+
+func (e *PutErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d of %d requests failed: %s", len(e.Errs), e.Requests, strings.Join(msgs, "; "))
+}
+
+// PutMetricData ... put metric data to a custom namespace, split into requests within the API limit
+//
+// A failed request does not stop the rest, and the failures are returned as PutErrors
+// unless the data is put in a single request.
+func (c *CloudWatchInstance) PutMetricData(ctx context.Context, namespace string, data []*cloudwatch.MetricDatum) error {
+	var errs []error
+	requests := 0
+	for i := 0; i < len(data); i += maxMetricDataPerRequest {
+		j := i + maxMetricDataPerRequest
+		if j > len(data) {
+			j = len(data)
+		}
+
+		input := &cloudwatch.PutMetricDataInput{
+			Namespace:  aws.String(namespace),
+			MetricData: data[i:j],
+		}
+		requests++
+		if _, err := c.client.PutMetricDataWithContext(ctx, input); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case requests == 1:
+		return errs[0]
+	}
+	return &PutErrors{Errs: errs, Requests: requests}
+}
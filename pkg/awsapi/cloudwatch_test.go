@@ -0,0 +1,110 @@
+package awsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/aws/aws-sdk-go/service/cloudwatch/cloudwatchiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockCloudWatchClient struct {
+	cloudwatchiface.CloudWatchAPI
+
+	inputs []*cloudwatch.PutMetricDataInput
+	Error  error
+}
+
+func (m *mockCloudWatchClient) PutMetricDataWithContext(ctx aws.Context, input *cloudwatch.PutMetricDataInput, opts ...request.Option) (*cloudwatch.PutMetricDataOutput, error) {
+	m.inputs = append(m.inputs, input)
+	return &cloudwatch.PutMetricDataOutput{}, m.Error
+}
+
+func metricData(n int) []*cloudwatch.MetricDatum {
+	data := []*cloudwatch.MetricDatum{}
+	for i := 0; i < n; i++ {
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String("aws.ri.coverage"),
+			Dimensions: []*cloudwatch.Dimension{
+				{
+					Name:  aws.String("instance_type"),
+					Value: aws.String(fmt.Sprintf("t3.nano%d", i)),
+				},
+			},
+			Value: aws.Float64(float64(i)),
+		})
+	}
+	return data
+}
+
+// API の上限 20 件ごとにリクエストを分割して送信する
+func TestPutMetricData(t *testing.T) {
+	mock := &mockCloudWatchClient{}
+	m := NewCloudWatch(mock)
+
+	if err := m.PutMetricData(context.Background(), "RIUtilizationPlotter", metricData(45)); err != nil {
+		t.Error(err)
+	}
+
+	expected := []int{20, 20, 5}
+	actual := []int{}
+	for _, input := range mock.inputs {
+		if *input.Namespace != "RIUtilizationPlotter" {
+			t.Errorf("wrong namespace : %s", *input.Namespace)
+		}
+		actual = append(actual, len(input.MetricData))
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestPutMetricDataEmpty(t *testing.T) {
+	mock := &mockCloudWatchClient{}
+	m := NewCloudWatch(mock)
+
+	if err := m.PutMetricData(context.Background(), "RIUtilizationPlotter", metricData(0)); err != nil {
+		t.Error(err)
+	}
+	if len(mock.inputs) != 0 {
+		t.Errorf("wrong result : %d requests are sent", len(mock.inputs))
+	}
+}
+
+func TestPutMetricDataFailed(t *testing.T) {
+	mock := &mockCloudWatchClient{
+		Error: errors.New("error occured"),
+	}
+	m := NewCloudWatch(mock)
+
+	// 失敗したリクエストがあっても残りのリクエストを送信する
+	err := m.PutMetricData(context.Background(), "RIUtilizationPlotter", metricData(45))
+	putErrs, ok := err.(*PutErrors)
+	if !ok {
+		t.Fatalf("wrong result : %v", err)
+	}
+	if len(putErrs.Errs) != 3 || putErrs.Requests != 3 {
+		t.Errorf("wrong result : %s", err)
+	}
+	if len(mock.inputs) != 3 {
+		t.Errorf("wrong result : %d requests are sent", len(mock.inputs))
+	}
+}
+
+// リクエストが 1 つなら失敗をそのまま返す
+func TestPutMetricDataFailedOnce(t *testing.T) {
+	mock := &mockCloudWatchClient{
+		Error: errors.New("error occured"),
+	}
+	m := NewCloudWatch(mock)
+
+	err := m.PutMetricData(context.Background(), "RIUtilizationPlotter", metricData(5))
+	if diff := cmp.Diff("error occured", fmt.Sprint(err)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
@@ -1,91 +1,469 @@
 package awsapi
 
 import (
+	"context"
+	"strconv"
+	"time"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/costexplorer"
 	"github.com/aws/aws-sdk-go/service/costexplorer/costexploreriface"
+	"github.com/cenkalti/backoff"
 )
 
 // CostexplorerIface : costexplorer interface
 type CostexplorerIface interface {
-	FetchRIUtilizationPercentage(service, startDay, endDay string) (string, error)
-	FetchRICoveragePercentage(service, startDay, endDay string) ([]*costexplorer.ReservationCoverageGroup, error)
+	FetchReservedServices(startDay, endDay string) ([]string, error)
+	FetchRIUtilization(service, startDay, endDay, granularity string) ([]*RIUtilization, error)
+	FetchRIUtilizationBySubscription(service, startDay, endDay string) ([]*RISubscriptionUtilization, error)
+	FetchRIUtilizationByLinkedAccount(service, startDay, endDay string) ([]*RILinkedAccountUtilization, error)
+	FetchRICoverage(service, startDay, endDay, granularity string) ([]*RICoverage, error)
+	FetchRICoverageByLinkedAccount(service, startDay, endDay, granularity string) ([]*RICoverage, error)
+	FetchSavingsPlansUtilization(startDay, endDay, granularity string) ([]*SavingsPlansUtilization, error)
+	FetchSavingsPlansUtilizationDetails(startDay, endDay string) ([]*SavingsPlansUtilization, error)
+	FetchSavingsPlansCoverage(startDay, endDay, granularity string) ([]*SavingsPlansCoverage, error)
 }
 
 // CostexplorerInstance : costexplorer instance
 type CostexplorerInstance struct {
-	client costexploreriface.CostExplorerAPI
+	client          costexploreriface.CostExplorerAPI
+	linkedAccount   string
+	coverageGroupBy []string
+	// limiter : limiter of the requests, nil for no limit
+	limiter *RateLimiter
+	// maxRetries : retries of a request failing temporarily
+	maxRetries int
+	// newBackOff : generate the backoff between the retries of a request
+	newBackOff func() backoff.BackOff
+	// ctx : context of the requests and the retries, which stop once it is done
+	ctx context.Context
+}
+
+// CostexplorerOption : option of the costexplorer client
+type CostexplorerOption func(*CostexplorerInstance)
+
+// WithCoverageGroupBy ... group RI coverage by the dimensions instead of REGION and INSTANCE_TYPE
+func WithCoverageGroupBy(dimensions ...string) CostexplorerOption {
+	return func(c *CostexplorerInstance) {
+		c.coverageGroupBy = dimensions
+	}
+}
+
+// WithLinkedAccount ... restrict Cost Explorer data to a linked account of the organization
+func WithLinkedAccount(accountID string) CostexplorerOption {
+	return func(c *CostexplorerInstance) {
+		c.linkedAccount = accountID
+	}
+}
+
+// WithRateLimiter ... wait for the limiter before every request, which may be shared by clients
+func WithRateLimiter(limiter *RateLimiter) CostexplorerOption {
+	return func(c *CostexplorerInstance) {
+		c.limiter = limiter
+	}
+}
+
+// WithContext ... send the requests with the context, so that neither a request nor its retries outlive it
+func WithContext(ctx context.Context) CostexplorerOption {
+	return func(c *CostexplorerInstance) {
+		c.ctx = ctx
+	}
+}
+
+// RIUtilization : aggregates of RI utilization
+type RIUtilization struct {
+	// Start : start of the time period of the aggregates
+	Start                     time.Time
+	UtilizationPercentage     float64
+	PurchasedHours            float64
+	TotalActualHours          float64
+	UnusedHours               float64
+	OnDemandCostOfRIHoursUsed float64
+	NetRISavings              float64
+	TotalPotentialRISavings   float64
+	AmortizedUpfrontFee       float64
+	AmortizedRecurringFee     float64
+	TotalAmortizedFee         float64
+}
+
+func newRIUtilization(start time.Time, a *costexplorer.ReservationAggregates) *RIUtilization {
+	return &RIUtilization{
+		Start:                     start,
+		UtilizationPercentage:     parseFloat(a.UtilizationPercentage),
+		PurchasedHours:            parseFloat(a.PurchasedHours),
+		TotalActualHours:          parseFloat(a.TotalActualHours),
+		UnusedHours:               parseFloat(a.UnusedHours),
+		OnDemandCostOfRIHoursUsed: parseFloat(a.OnDemandCostOfRIHoursUsed),
+		NetRISavings:              parseFloat(a.NetRISavings),
+		TotalPotentialRISavings:   parseFloat(a.TotalPotentialRISavings),
+		AmortizedUpfrontFee:       parseFloat(a.AmortizedUpfrontFee),
+		AmortizedRecurringFee:     parseFloat(a.AmortizedRecurringFee),
+		TotalAmortizedFee:         parseFloat(a.TotalAmortizedFee),
+	}
+}
+
+// RISubscriptionUtilization : utilization of a reservation
+type RISubscriptionUtilization struct {
+	SubscriptionID    string
+	InstanceType      string
+	Region            string
+	EndDateTime       string
+	NumberOfInstances string
+	Utilization       *RIUtilization
+}
+
+// RILinkedAccountUtilization : utilization of the reservations of a linked account
+type RILinkedAccountUtilization struct {
+	LinkedAccount string
+	Utilization   *RIUtilization
+}
+
+// RICoverage : RI coverage of a group, e.g. an instance type in a region or a linked account
+type RICoverage struct {
+	// Start : start of the time period of the coverage
+	Start time.Time
+	// Attributes : values of the group-by dimensions, e.g. region and instanceType
+	Attributes              map[string]string
+	LinkedAccount           string
+	CoverageHoursPercentage float64
+	OnDemandHours           float64
+	ReservedHours           float64
+	TotalRunningHours       float64
+	OnDemandCost            float64
+	// NormalizedUnits : nil unless the service supports size flexible RIs
+	NormalizedUnits *RICoverageNormalizedUnits
+}
+
+// RICoverageNormalizedUnits : RI coverage in normalized units
+type RICoverageNormalizedUnits struct {
+	CoveragePercentage float64
+	OnDemand           float64
+	Reserved           float64
+	TotalRunning       float64
+}
+
+func newRICoverage(start time.Time, g *costexplorer.ReservationCoverageGroup) *RICoverage {
+	c := &RICoverage{
+		Start:         start,
+		Attributes:    aws.StringValueMap(g.Attributes),
+		LinkedAccount: aws.StringValue(g.Attributes["linkedAccount"]),
+	}
+	if g.Coverage == nil {
+		return c
+	}
+
+	if h := g.Coverage.CoverageHours; h != nil {
+		c.CoverageHoursPercentage = parseFloat(h.CoverageHoursPercentage)
+		c.OnDemandHours = parseFloat(h.OnDemandHours)
+		c.ReservedHours = parseFloat(h.ReservedHours)
+		c.TotalRunningHours = parseFloat(h.TotalRunningHours)
+	}
+	if cost := g.Coverage.CoverageCost; cost != nil {
+		c.OnDemandCost = parseFloat(cost.OnDemandCost)
+	}
+	if u := g.Coverage.CoverageNormalizedUnits; u != nil {
+		c.NormalizedUnits = &RICoverageNormalizedUnits{
+			CoveragePercentage: parseFloat(u.CoverageNormalizedUnitsPercentage),
+			OnDemand:           parseFloat(u.OnDemandNormalizedUnits),
+			Reserved:           parseFloat(u.ReservedNormalizedUnits),
+			TotalRunning:       parseFloat(u.TotalRunningNormalizedUnits),
+		}
+	}
+	return c
+}
+
+// parseFloat ... string to float64, 0 if the value is missing or malformed
+func parseFloat(s *string) float64 {
+	f, _ := strconv.ParseFloat(aws.StringValue(s), 64)
+	return f
+}
+
+// periodStart ... start of the time period, the zero time if the period is missing or malformed
+func periodStart(p *costexplorer.DateInterval) time.Time {
+	if p == nil {
+		return time.Time{}
+	}
+	t, _ := time.Parse("2006-01-02", aws.StringValue(p.Start))
+	return t
 }
 
 // NewCostexplorer ... generate new costexplorer client
-func NewCostexplorer(client costexploreriface.CostExplorerAPI) CostexplorerIface {
-	return &CostexplorerInstance{
-		client: client,
+func NewCostexplorer(client costexploreriface.CostExplorerAPI, options ...CostexplorerOption) CostexplorerIface {
+	c := &CostexplorerInstance{
+		client:          client,
+		coverageGroupBy: []string{"REGION", "INSTANCE_TYPE"},
+		newBackOff:      newJitteredBackOff,
+		ctx:             context.Background(),
+	}
+	for _, option := range options {
+		option(c)
 	}
+	return c
 }
 
-// FetchRIUtilizationPercentage ... fetch RI Utilization Percentage
-func (c *CostexplorerInstance) FetchRIUtilizationPercentage(service, startDay, endDay string) (riUtilPct string, err error) {
-	input := &costexplorer.GetReservationUtilizationInput{
-		Granularity: aws.String("DAILY"),
+// dimension ... expression matching the value of the dimension
+func dimension(key, value string) *costexplorer.Expression {
+	return &costexplorer.Expression{
+		Dimensions: &costexplorer.DimensionValues{
+			Key: aws.String(key),
+			Values: []*string{
+				aws.String(value),
+			},
+		},
+	}
+}
+
+// serviceFilter ... expression matching the service, and the linked account if the client is restricted to it
+func (c *CostexplorerInstance) serviceFilter(service string) *costexplorer.Expression {
+	if c.linkedAccount == "" {
+		return dimension("SERVICE", service)
+	}
+	return &costexplorer.Expression{
+		And: []*costexplorer.Expression{
+			dimension("SERVICE", service),
+			dimension("LINKED_ACCOUNT", c.linkedAccount),
+		},
+	}
+}
+
+// accountFilter ... expression matching the linked account, nil if the client is not restricted to it
+func (c *CostexplorerInstance) accountFilter() *costexplorer.Expression {
+	if c.linkedAccount == "" {
+		return nil
+	}
+	return dimension("LINKED_ACCOUNT", c.linkedAccount)
+}
+
+// FetchReservedServices ... fetch services with reservations in the period
+func (c *CostexplorerInstance) FetchReservedServices(startDay, endDay string) ([]string, error) {
+	input := &costexplorer.GetDimensionValuesInput{
+		Context:   aws.String(costexplorer.ContextReservations),
+		Dimension: aws.String(costexplorer.DimensionService),
 		TimePeriod: &costexplorer.DateInterval{
 			Start: aws.String(startDay),
 			End:   aws.String(endDay),
 		},
-		Filter: &costexplorer.Expression{
-			Dimensions: &costexplorer.DimensionValues{
-				Key: aws.String("SERVICE"),
-				Values: []*string{
-					aws.String(service),
-				},
-			},
-		},
 	}
-	r, err := c.client.GetReservationUtilization(input)
-	if err != nil {
-		return riUtilPct, err
+
+	services := []string{}
+	for {
+		var r *costexplorer.GetDimensionValuesOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetDimensionValuesWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []string{}, err
+		}
+		for _, v := range r.DimensionValues {
+			services = append(services, aws.StringValue(v.Value))
+		}
+
+		if aws.StringValue(r.NextPageToken) == "" {
+			break
+		}
+		input.NextPageToken = r.NextPageToken
 	}
+	return services, nil
+}
 
-	// You do not use this service
-	if len(r.UtilizationsByTime) == 0 {
-		return
+// FetchRIUtilization ... fetch RI Utilization aggregates of each time period, empty if you do not use the service
+func (c *CostexplorerInstance) FetchRIUtilization(service, startDay, endDay, granularity string) ([]*RIUtilization, error) {
+	input := &costexplorer.GetReservationUtilizationInput{
+		Granularity: aws.String(granularity),
+		TimePeriod: &costexplorer.DateInterval{
+			Start: aws.String(startDay),
+			End:   aws.String(endDay),
+		},
+		Filter: c.serviceFilter(service),
 	}
+	utils := []*RIUtilization{}
+	for {
+		var r *costexplorer.GetReservationUtilizationOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetReservationUtilizationWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []*RIUtilization{}, err
+		}
 
-	return *r.UtilizationsByTime[0].Total.UtilizationPercentage, nil
+		// UtilizationsByTime is empty if you do not use this service
+		for _, u := range r.UtilizationsByTime {
+			if u.Total == nil {
+				continue
+			}
+			utils = append(utils, newRIUtilization(periodStart(u.TimePeriod), u.Total))
+		}
+
+		if aws.StringValue(r.NextPageToken) == "" {
+			break
+		}
+		input.NextPageToken = r.NextPageToken
+	}
+	return utils, nil
 }
 
-// FetchRICoveragePercentage ... fetch RI Coverage Percentage
-func (c *CostexplorerInstance) FetchRICoveragePercentage(service, startDay, endDay string) ([]*costexplorer.ReservationCoverageGroup, error) {
-	input := &costexplorer.GetReservationCoverageInput{
+// FetchRIUtilizationBySubscription ... fetch RI Utilization of each reservation
+//
+// The utilization covers the whole period, stamped at the start of the period.
+func (c *CostexplorerInstance) FetchRIUtilizationBySubscription(service, startDay, endDay string) ([]*RISubscriptionUtilization, error) {
+	// Granularity cannot be set with GroupBy
+	input := &costexplorer.GetReservationUtilizationInput{
 		TimePeriod: &costexplorer.DateInterval{
 			Start: aws.String(startDay),
 			End:   aws.String(endDay),
 		},
-		Filter: &costexplorer.Expression{
-			Dimensions: &costexplorer.DimensionValues{
-				Key: aws.String("SERVICE"),
-				Values: []*string{
-					aws.String(service),
-				},
-			},
-		},
+		Filter: c.serviceFilter(service),
 		GroupBy: []*costexplorer.GroupDefinition{
 			{
 				Type: aws.String("DIMENSION"),
-				Key:  aws.String("REGION"),
+				Key:  aws.String("SUBSCRIPTION_ID"),
 			},
+		},
+	}
+	utils := []*RISubscriptionUtilization{}
+	for {
+		var r *costexplorer.GetReservationUtilizationOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetReservationUtilizationWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []*RISubscriptionUtilization{}, err
+		}
+
+		// UtilizationsByTime is empty if you do not use this service
+		for _, u := range r.UtilizationsByTime {
+			for _, g := range u.Groups {
+				if g.Utilization == nil {
+					continue
+				}
+				utils = append(utils, &RISubscriptionUtilization{
+					SubscriptionID:    aws.StringValue(g.Value),
+					InstanceType:      aws.StringValue(g.Attributes["instanceType"]),
+					Region:            aws.StringValue(g.Attributes["region"]),
+					EndDateTime:       aws.StringValue(g.Attributes["endDateTime"]),
+					NumberOfInstances: aws.StringValue(g.Attributes["numberOfInstances"]),
+					Utilization:       newRIUtilization(periodStart(u.TimePeriod), g.Utilization),
+				})
+			}
+		}
+
+		if aws.StringValue(r.NextPageToken) == "" {
+			break
+		}
+		input.NextPageToken = r.NextPageToken
+	}
+	return utils, nil
+}
+
+// FetchRIUtilizationByLinkedAccount ... fetch RI Utilization of each linked account
+//
+// The utilization covers the whole period, stamped at the start of the period.
+func (c *CostexplorerInstance) FetchRIUtilizationByLinkedAccount(service, startDay, endDay string) ([]*RILinkedAccountUtilization, error) {
+	// Granularity cannot be set with GroupBy
+	input := &costexplorer.GetReservationUtilizationInput{
+		TimePeriod: &costexplorer.DateInterval{
+			Start: aws.String(startDay),
+			End:   aws.String(endDay),
+		},
+		Filter: c.serviceFilter(service),
+		GroupBy: []*costexplorer.GroupDefinition{
 			{
 				Type: aws.String("DIMENSION"),
-				Key:  aws.String("INSTANCE_TYPE"),
+				Key:  aws.String("LINKED_ACCOUNT"),
 			},
 		},
 	}
 
-	r, err := c.client.GetReservationCoverage(input)
+	utils := []*RILinkedAccountUtilization{}
+	for {
+		var r *costexplorer.GetReservationUtilizationOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetReservationUtilizationWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []*RILinkedAccountUtilization{}, err
+		}
+
+		// UtilizationsByTime is empty if you do not use this service
+		for _, u := range r.UtilizationsByTime {
+			for _, g := range u.Groups {
+				if g.Utilization == nil {
+					continue
+				}
+				utils = append(utils, &RILinkedAccountUtilization{
+					LinkedAccount: aws.StringValue(g.Value),
+					Utilization:   newRIUtilization(periodStart(u.TimePeriod), g.Utilization),
+				})
+			}
+		}
 
-	if err != nil {
-		return []*costexplorer.ReservationCoverageGroup{}, err
+		if aws.StringValue(r.NextPageToken) == "" {
+			break
+		}
+		input.NextPageToken = r.NextPageToken
 	}
+	return utils, nil
+}
+
+// FetchRICoverage ... fetch RI Coverage of each group and time period, grouped by region and instance type by default
+func (c *CostexplorerInstance) FetchRICoverage(service, startDay, endDay, granularity string) ([]*RICoverage, error) {
+	return c.fetchRICoverage(service, startDay, endDay, granularity, c.coverageGroupBy...)
+}
 
-	return r.CoveragesByTime[0].Groups, nil
+// FetchRICoverageByLinkedAccount ... fetch RI Coverage of each linked account and time period
+func (c *CostexplorerInstance) FetchRICoverageByLinkedAccount(service, startDay, endDay, granularity string) ([]*RICoverage, error) {
+	return c.fetchRICoverage(service, startDay, endDay, granularity, "LINKED_ACCOUNT")
+}
+
+func (c *CostexplorerInstance) fetchRICoverage(service, startDay, endDay, granularity string, dimensions ...string) ([]*RICoverage, error) {
+	groupBy := []*costexplorer.GroupDefinition{}
+	for _, d := range dimensions {
+		groupBy = append(groupBy, &costexplorer.GroupDefinition{
+			Type: aws.String("DIMENSION"),
+			Key:  aws.String(d),
+		})
+	}
+
+	input := &costexplorer.GetReservationCoverageInput{
+		Granularity: aws.String(granularity),
+		TimePeriod: &costexplorer.DateInterval{
+			Start: aws.String(startDay),
+			End:   aws.String(endDay),
+		},
+		Filter:  c.serviceFilter(service),
+		GroupBy: groupBy,
+		Metrics: []*string{
+			aws.String("Hour"),
+			aws.String("Unit"),
+			aws.String("Cost"),
+		},
+	}
+
+	coverages := []*RICoverage{}
+	for {
+		var r *costexplorer.GetReservationCoverageOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetReservationCoverageWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []*RICoverage{}, err
+		}
+
+		for _, cov := range r.CoveragesByTime {
+			for _, g := range cov.Groups {
+				coverages = append(coverages, newRICoverage(periodStart(cov.TimePeriod), g))
+			}
+		}
+
+		if aws.StringValue(r.NextPageToken) == "" {
+			break
+		}
+		input.NextPageToken = r.NextPageToken
+	}
+	return coverages, nil
 }
@@ -2,11 +2,13 @@ package awsapi
 
 import (
 	"errors"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/endpoints"
+	"github.com/aws/aws-sdk-go/aws/request"
 	"github.com/aws/aws-sdk-go/service/costexplorer"
 	"github.com/aws/aws-sdk-go/service/costexplorer/costexploreriface"
 	"github.com/google/go-cmp/cmp"
@@ -15,23 +17,136 @@ import (
 type mockCostExplorerClient struct {
 	costexploreriface.CostExplorerAPI
 
-	reservationUtilizationOutput *costexplorer.GetReservationUtilizationOutput
-	reservationCoverageOutput    *costexplorer.GetReservationCoverageOutput
-	Error                        error
+	savingsPlansUtilizationOutput *costexplorer.GetSavingsPlansUtilizationOutput
+	// pages of the responses, NextPageToken or NextToken is the index of the next page
+	reservationUtilizationOutputs         []*costexplorer.GetReservationUtilizationOutput
+	reservationCoverageOutputs            []*costexplorer.GetReservationCoverageOutput
+	dimensionValuesOutputs                []*costexplorer.GetDimensionValuesOutput
+	savingsPlansUtilizationDetailsOutputs []*costexplorer.GetSavingsPlansUtilizationDetailsOutput
+	savingsPlansCoverageOutputs           []*costexplorer.GetSavingsPlansCoverageOutput
+	Error                                 error
+	// errors : errors of the requests in order, followed by Error
+	errors []error
+	// attempts : number of the requests
+	attempts int
+	// ctx : context of the last request
+	ctx aws.Context
+
+	// the last inputs of GetReservationUtilization and GetReservationCoverage
+	reservationUtilizationInput *costexplorer.GetReservationUtilizationInput
+	reservationCoverageInput    *costexplorer.GetReservationCoverageInput
+}
+
+func (m *mockCostExplorerClient) GetReservationUtilizationWithContext(ctx aws.Context, input *costexplorer.GetReservationUtilizationInput, _ ...request.Option) (*costexplorer.GetReservationUtilizationOutput, error) {
+	m.reservationUtilizationInput = input
+	if err := m.err(ctx); err != nil {
+		return nil, err
+	}
+	return m.reservationUtilizationOutputs[page(input.NextPageToken)], nil
+}
+
+func (m *mockCostExplorerClient) GetReservationCoverageWithContext(ctx aws.Context, input *costexplorer.GetReservationCoverageInput, _ ...request.Option) (*costexplorer.GetReservationCoverageOutput, error) {
+	m.reservationCoverageInput = input
+	if err := m.err(ctx); err != nil {
+		return nil, err
+	}
+	return m.reservationCoverageOutputs[page(input.NextPageToken)], nil
 }
 
-func (m *mockCostExplorerClient) GetReservationUtilization(*costexplorer.GetReservationUtilizationInput) (*costexplorer.GetReservationUtilizationOutput, error) {
-	return m.reservationUtilizationOutput, m.Error
+func (m *mockCostExplorerClient) GetSavingsPlansUtilizationWithContext(ctx aws.Context, _ *costexplorer.GetSavingsPlansUtilizationInput, _ ...request.Option) (*costexplorer.GetSavingsPlansUtilizationOutput, error) {
+	if err := m.err(ctx); err != nil {
+		return nil, err
+	}
+	return m.savingsPlansUtilizationOutput, nil
+}
+
+func (m *mockCostExplorerClient) GetSavingsPlansUtilizationDetailsWithContext(ctx aws.Context, input *costexplorer.GetSavingsPlansUtilizationDetailsInput, _ ...request.Option) (*costexplorer.GetSavingsPlansUtilizationDetailsOutput, error) {
+	if err := m.err(ctx); err != nil {
+		return nil, err
+	}
+	return m.savingsPlansUtilizationDetailsOutputs[page(input.NextToken)], nil
+}
+
+func (m *mockCostExplorerClient) GetSavingsPlansCoverageWithContext(ctx aws.Context, input *costexplorer.GetSavingsPlansCoverageInput, _ ...request.Option) (*costexplorer.GetSavingsPlansCoverageOutput, error) {
+	if err := m.err(ctx); err != nil {
+		return nil, err
+	}
+	return m.savingsPlansCoverageOutputs[page(input.NextToken)], nil
+}
+
+func (m *mockCostExplorerClient) GetDimensionValuesWithContext(ctx aws.Context, input *costexplorer.GetDimensionValuesInput, _ ...request.Option) (*costexplorer.GetDimensionValuesOutput, error) {
+	if err := m.err(ctx); err != nil {
+		return nil, err
+	}
+	return m.dimensionValuesOutputs[page(input.NextPageToken)], nil
+}
+
+// err ... error of the request sent with the context
+func (m *mockCostExplorerClient) err(ctx aws.Context) error {
+	m.attempts++
+	m.ctx = ctx
+	if len(m.errors) > 0 {
+		err := m.errors[0]
+		m.errors = m.errors[1:]
+		return err
+	}
+	return m.Error
+}
+
+// page ... index of the page which the token points to
+func page(token *string) int {
+	i, _ := strconv.Atoi(aws.StringValue(token))
+	return i
+}
+
+// 全ページから RI を購入しているサービスを取得する
+func TestFetchReservedServices(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		dimensionValuesOutputs: []*costexplorer.GetDimensionValuesOutput{
+			{
+				DimensionValues: []*costexplorer.DimensionValuesWithAttributes{
+					{Value: aws.String("Amazon Elastic Compute Cloud - Compute")},
+					{Value: aws.String("Amazon OpenSearch Service")},
+				},
+				NextPageToken: aws.String("1"),
+			},
+			{
+				DimensionValues: []*costexplorer.DimensionValuesWithAttributes{
+					{Value: aws.String("Amazon MemoryDB")},
+				},
+			},
+		},
+	})
+
+	services, err := m.FetchReservedServices("2019-12-20", "2019-12-22")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []string{
+		"Amazon Elastic Compute Cloud - Compute",
+		"Amazon OpenSearch Service",
+		"Amazon MemoryDB",
+	}
+	if diff := cmp.Diff(expected, services); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
 }
 
-func (m *mockCostExplorerClient) GetReservationCoverage(*costexplorer.GetReservationCoverageInput) (*costexplorer.GetReservationCoverageOutput, error) {
-	return m.reservationCoverageOutput, m.Error
+func TestFetchReservedServicesFailed(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		Error: errors.New("error occured"),
+	})
+
+	if _, err := m.FetchReservedServices("2019-12-20", "2019-12-22"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
 }
 
 // 正常に RI Utilization 取得
-func TestFetchRIUtilizationPercentageSuccessfully(t *testing.T) {
+func TestFetchRIUtilizationSuccessfully(t *testing.T) {
 	m := NewCostexplorer(&mockCostExplorerClient{
-		reservationUtilizationOutput: &costexplorer.GetReservationUtilizationOutput{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{
 			Total: &costexplorer.ReservationAggregates{
 				UtilizationPercentage:     aws.String("100"),
 				PurchasedHours:            aws.String("48.0"),
@@ -64,7 +179,7 @@ func TestFetchRIUtilizationPercentageSuccessfully(t *testing.T) {
 					},
 				},
 			},
-		},
+		}},
 		Error: nil,
 	})
 
@@ -74,21 +189,33 @@ func TestFetchRIUtilizationPercentageSuccessfully(t *testing.T) {
 	startDay := now.AddDate(0, 0, -2).Format("2006-01-02")
 	endDay := now.Format("2006-01-02")
 
-	utilPercentage, err := m.FetchRIUtilizationPercentage(service, startDay, endDay)
+	util, err := m.FetchRIUtilization(service, startDay, endDay, "DAILY")
 	if err != nil {
 		t.Error(err)
 	}
 
-	expected := "100"
-	if diff := cmp.Diff(expected, utilPercentage); diff != "" {
+	expected := []*RIUtilization{{
+		Start:                     time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC),
+		UtilizationPercentage:     100,
+		PurchasedHours:            48,
+		TotalActualHours:          48,
+		UnusedHours:               0,
+		OnDemandCostOfRIHoursUsed: 0.326,
+		NetRISavings:              0.116690411,
+		TotalPotentialRISavings:   0.11669,
+		AmortizedUpfrontFee:       0.104109589,
+		AmortizedRecurringFee:     0.1056,
+		TotalAmortizedFee:         0.209709589,
+	}}
+	if diff := cmp.Diff(expected, util); diff != "" {
 		t.Errorf("wront result : %s", diff)
 	}
 }
 
-// UtilizationsByTime が空 そもそも指定の service を利用していないケースは "" を返す
-func TestFetchRIUtilizationPercentageNoUseTheService(t *testing.T) {
+// UtilizationsByTime が空 そもそも指定の service を利用していないケースは空を返す
+func TestFetchRIUtilizationNoUseTheService(t *testing.T) {
 	m := NewCostexplorer(&mockCostExplorerClient{
-		reservationUtilizationOutput: &costexplorer.GetReservationUtilizationOutput{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{
 			Total: &costexplorer.ReservationAggregates{
 				UtilizationPercentage:     aws.String("100"),
 				PurchasedHours:            aws.String("48.0"),
@@ -102,7 +229,7 @@ func TestFetchRIUtilizationPercentageNoUseTheService(t *testing.T) {
 				TotalAmortizedFee:         aws.String("0.20970958903225806"),
 			},
 			UtilizationsByTime: []*costexplorer.UtilizationByTime{},
-		},
+		}},
 		Error: nil,
 	})
 
@@ -112,22 +239,89 @@ func TestFetchRIUtilizationPercentageNoUseTheService(t *testing.T) {
 	startDay := now.AddDate(0, 0, -2).Format("2006-01-02")
 	endDay := now.Format("2006-01-02")
 
-	utilPct, err := m.FetchRIUtilizationPercentage(service, startDay, endDay)
+	util, err := m.FetchRIUtilization(service, startDay, endDay, "DAILY")
 	if err != nil {
 		t.Error(err)
 	}
 
-	expected := ""
-	if diff := cmp.Diff(expected, utilPct); diff != "" {
-		t.Errorf("wront result : %s", diff)
+	if len(util) != 0 {
+		t.Errorf("wront result : %v", util)
+	}
+}
+
+// 複数の期間・ページに分かれた RI Utilization を全て取得する
+func TestFetchRIUtilizationPaginated(t *testing.T) {
+	byTime := func(startDay, endDay, percentage string) *costexplorer.UtilizationByTime {
+		return &costexplorer.UtilizationByTime{
+			TimePeriod: &costexplorer.DateInterval{
+				Start: aws.String(startDay),
+				End:   aws.String(endDay),
+			},
+			Total: &costexplorer.ReservationAggregates{
+				UtilizationPercentage: aws.String(percentage),
+			},
+		}
+	}
+	m := NewCostexplorer(&mockCostExplorerClient{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{
+			{
+				UtilizationsByTime: []*costexplorer.UtilizationByTime{
+					byTime("2019-12-20", "2019-12-21", "100"),
+					byTime("2019-12-21", "2019-12-22", "90"),
+				},
+				NextPageToken: aws.String("1"),
+			},
+			{
+				UtilizationsByTime: []*costexplorer.UtilizationByTime{
+					byTime("2019-12-22", "2019-12-23", "80"),
+				},
+			},
+		},
+	})
+
+	utils, err := m.FetchRIUtilization("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-23", "DAILY")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*RIUtilization{
+		{Start: time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC), UtilizationPercentage: 100},
+		{Start: time.Date(2019, 12, 21, 0, 0, 0, 0, time.UTC), UtilizationPercentage: 90},
+		{Start: time.Date(2019, 12, 22, 0, 0, 0, 0, time.UTC), UtilizationPercentage: 80},
+	}
+	if diff := cmp.Diff(expected, utils); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 各ページのリクエストの前に limiter で待つ
+func TestFetchRIUtilizationWithRateLimiter(t *testing.T) {
+	limiter := NewRateLimiter(1)
+	waits := 0
+	limiter.sleep = func(time.Duration) {
+		waits++
+	}
+	m := NewCostexplorer(&mockCostExplorerClient{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{
+			{NextPageToken: aws.String("1")},
+			{NextPageToken: aws.String("2")},
+			{},
+		},
+	}, WithRateLimiter(limiter))
+
+	if _, err := m.FetchRIUtilization("Amazon Redshift", "2019-12-20", "2019-12-23", "DAILY"); err != nil {
+		t.Fatal(err)
+	}
+	// 最初のリクエストは待たない
+	if waits != 2 {
+		t.Errorf("wrong result : waited %d times", waits)
 	}
 }
 
 // 開始期間と終了時間を最低でも 2 日間開けていないと RI Utilization 取得 API はエラーとなる
-func TestFetchRIUtilizationPercentageFailed(t *testing.T) {
+func TestFetchRIUtilizationFailed(t *testing.T) {
 	m := NewCostexplorer(&mockCostExplorerClient{
-		reservationUtilizationOutput: &costexplorer.GetReservationUtilizationOutput{},
-		Error:                        errors.New("error occured"),
+		Error: errors.New("error occured"),
 	})
 
 	service := "Amazon Elastic Compute Cloud - Compute"
@@ -136,16 +330,170 @@ func TestFetchRIUtilizationPercentageFailed(t *testing.T) {
 	startDay := now.AddDate(0, 0, -2).Format("2006-01-02")
 	endDay := now.Format("2006-01-02")
 
-	_, err := m.FetchRIUtilizationPercentage(service, startDay, endDay)
+	_, err := m.FetchRIUtilization(service, startDay, endDay, "DAILY")
 	if err == nil {
 		t.Error("wrong result : err is null")
 	}
 }
 
+// 正常に RI ごとの Utilization を取得
+func TestFetchRIUtilizationBySubscription(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{
+			UtilizationsByTime: []*costexplorer.UtilizationByTime{
+				{
+					TimePeriod: &costexplorer.DateInterval{
+						Start: aws.String("2019-12-20"),
+						End:   aws.String("2019-12-21"),
+					},
+					Groups: []*costexplorer.ReservationUtilizationGroup{
+						{
+							Key:   aws.String("SUBSCRIPTION_ID"),
+							Value: aws.String("1234567890"),
+							Attributes: map[string]*string{
+								"instanceType":      aws.String("t3.nano"),
+								"region":            aws.String(endpoints.ApNortheast1RegionID),
+								"endDateTime":       aws.String("2020-12-20T00:00:00.000Z"),
+								"numberOfInstances": aws.String("2"),
+							},
+							Utilization: &costexplorer.ReservationAggregates{
+								UtilizationPercentage: aws.String("50"),
+								PurchasedHours:        aws.String("48"),
+								TotalActualHours:      aws.String("24"),
+								UnusedHours:           aws.String("24"),
+							},
+						},
+					},
+				},
+			},
+		}},
+	})
+
+	// 期間全体の集計になるので、1 日ずつ取得する
+	utils, err := m.FetchRIUtilizationBySubscription("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-21")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*RISubscriptionUtilization{
+		{
+			SubscriptionID:    "1234567890",
+			InstanceType:      "t3.nano",
+			Region:            endpoints.ApNortheast1RegionID,
+			EndDateTime:       "2020-12-20T00:00:00.000Z",
+			NumberOfInstances: "2",
+			Utilization: &RIUtilization{
+				Start:                 time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC),
+				UtilizationPercentage: 50,
+				PurchasedHours:        48,
+				TotalActualHours:      24,
+				UnusedHours:           24,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, utils); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 連結アカウントごとの RI Utilization を取得する
+func TestFetchRIUtilizationByLinkedAccount(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{
+			{
+				UtilizationsByTime: []*costexplorer.UtilizationByTime{
+					{
+						TimePeriod: &costexplorer.DateInterval{
+							Start: aws.String("2019-12-20"),
+							End:   aws.String("2019-12-21"),
+						},
+						Groups: []*costexplorer.ReservationUtilizationGroup{
+							{
+								Key:   aws.String("LINKED_ACCOUNT"),
+								Value: aws.String("111111111111"),
+								Utilization: &costexplorer.ReservationAggregates{
+									UtilizationPercentage: aws.String("50"),
+									UnusedHours:           aws.String("24"),
+								},
+							},
+							{
+								Key:   aws.String("LINKED_ACCOUNT"),
+								Value: aws.String("222222222222"),
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	// 期間全体の集計になるので、1 日ずつ取得する
+	utils, err := m.FetchRIUtilizationByLinkedAccount("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-21")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*RILinkedAccountUtilization{
+		{
+			LinkedAccount: "111111111111",
+			Utilization: &RIUtilization{
+				Start:                 time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC),
+				UtilizationPercentage: 50,
+				UnusedHours:           24,
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, utils); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 連結アカウントを指定したクライアントはサービスと連結アカウントの両方で絞り込む
+func TestFetchRIUtilizationWithLinkedAccount(t *testing.T) {
+	client := &mockCostExplorerClient{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{}},
+	}
+	m := NewCostexplorer(client, WithLinkedAccount("111111111111"))
+
+	if _, err := m.FetchRIUtilization("Amazon Redshift", "2019-12-20", "2019-12-22", "DAILY"); err != nil {
+		t.Error(err)
+	}
+
+	expected := &costexplorer.Expression{
+		And: []*costexplorer.Expression{
+			{
+				Dimensions: &costexplorer.DimensionValues{
+					Key:    aws.String("SERVICE"),
+					Values: []*string{aws.String("Amazon Redshift")},
+				},
+			},
+			{
+				Dimensions: &costexplorer.DimensionValues{
+					Key:    aws.String("LINKED_ACCOUNT"),
+					Values: []*string{aws.String("111111111111")},
+				},
+			},
+		},
+	}
+	if diff := cmp.Diff(expected, client.reservationUtilizationInput.Filter); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestFetchRIUtilizationBySubscriptionFailed(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		Error: errors.New("error occured"),
+	})
+
+	if _, err := m.FetchRIUtilizationBySubscription("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-22"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
 // 正常に RI Coverage が取得できる
-func TestFetchRICoveragePercentage(t *testing.T) {
+func TestFetchRICoverage(t *testing.T) {
 	m := NewCostexplorer(&mockCostExplorerClient{
-		reservationCoverageOutput: &costexplorer.GetReservationCoverageOutput{
+		reservationCoverageOutputs: []*costexplorer.GetReservationCoverageOutput{{
 			CoveragesByTime: []*costexplorer.CoverageByTime{
 				&costexplorer.CoverageByTime{
 					TimePeriod: &costexplorer.DateInterval{
@@ -159,12 +507,21 @@ func TestFetchRICoveragePercentage(t *testing.T) {
 								"region":       aws.String(endpoints.ApNortheast1RegionID),
 							},
 							Coverage: &costexplorer.Coverage{
+								CoverageCost: &costexplorer.CoverageCost{
+									OnDemandCost: aws.String("0.1632"),
+								},
 								CoverageHours: &costexplorer.CoverageHours{
 									OnDemandHours:           aws.String("24"),
 									ReservedHours:           aws.String("0"),
 									TotalRunningHours:       aws.String("24"),
 									CoverageHoursPercentage: aws.String("0"),
 								},
+								CoverageNormalizedUnits: &costexplorer.CoverageNormalizedUnits{
+									OnDemandNormalizedUnits:           aws.String("6"),
+									ReservedNormalizedUnits:           aws.String("0"),
+									TotalRunningNormalizedUnits:       aws.String("6"),
+									CoverageNormalizedUnitsPercentage: aws.String("0"),
+								},
 							},
 						},
 						&costexplorer.ReservationCoverageGroup{
@@ -192,7 +549,7 @@ func TestFetchRICoveragePercentage(t *testing.T) {
 					CoverageHoursPercentage: aws.String("66.6666666667"),
 				},
 			},
-		},
+		}},
 		Error: nil,
 	})
 
@@ -201,51 +558,195 @@ func TestFetchRICoveragePercentage(t *testing.T) {
 	now := time.Now()
 	startDay := now.AddDate(0, 0, -2).Format("2006-01-02")
 	endDay := now.Format("2006-01-02")
-	costCoverages, err := m.FetchRICoveragePercentage(service, startDay, endDay)
+	costCoverages, err := m.FetchRICoverage(service, startDay, endDay, "DAILY")
 	if err != nil {
 		t.Error(err)
 	}
 
-	expected := []*costexplorer.ReservationCoverageGroup{
-		&costexplorer.ReservationCoverageGroup{
+	expected := []*RICoverage{
+		{
+			Start: time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC),
+			Attributes: map[string]string{
+				"instanceType": "t3.nano",
+				"region":       endpoints.ApNortheast1RegionID,
+			},
+			CoverageHoursPercentage: 0,
+			OnDemandHours:           24,
+			ReservedHours:           0,
+			TotalRunningHours:       24,
+			OnDemandCost:            0.1632,
+			NormalizedUnits: &RICoverageNormalizedUnits{
+				CoveragePercentage: 0,
+				OnDemand:           6,
+				Reserved:           0,
+				TotalRunning:       6,
+			},
+		},
+		{
+			Start: time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC),
+			Attributes: map[string]string{
+				"instanceType": "t2.micro",
+				"region":       "ap-northeast-3",
+			},
+			CoverageHoursPercentage: 50,
+			OnDemandHours:           24,
+			ReservedHours:           24,
+			TotalRunningHours:       48,
+		},
+	}
+
+	if diff := cmp.Diff(expected, costCoverages); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 複数の期間・ページに分かれた RI Coverage を全て取得する
+func TestFetchRICoveragePaginated(t *testing.T) {
+	group := func(instanceType, percentage string) *costexplorer.ReservationCoverageGroup {
+		return &costexplorer.ReservationCoverageGroup{
 			Attributes: map[string]*string{
-				"instanceType": aws.String("t3.nano"),
+				"instanceType": aws.String(instanceType),
 				"region":       aws.String(endpoints.ApNortheast1RegionID),
 			},
 			Coverage: &costexplorer.Coverage{
 				CoverageHours: &costexplorer.CoverageHours{
-					OnDemandHours:           aws.String("24"),
-					ReservedHours:           aws.String("0"),
-					TotalRunningHours:       aws.String("24"),
-					CoverageHoursPercentage: aws.String("0"),
+					CoverageHoursPercentage: aws.String(percentage),
 				},
 			},
-		},
-		&costexplorer.ReservationCoverageGroup{
-			Attributes: map[string]*string{
-				"instanceType": aws.String("t2.micro"),
-				"region":       aws.String("ap-northeast-3"),
+		}
+	}
+	m := NewCostexplorer(&mockCostExplorerClient{
+		reservationCoverageOutputs: []*costexplorer.GetReservationCoverageOutput{
+			{
+				CoveragesByTime: []*costexplorer.CoverageByTime{
+					{
+						TimePeriod: &costexplorer.DateInterval{
+							Start: aws.String("2019-12-20"),
+							End:   aws.String("2019-12-21"),
+						},
+						Groups: []*costexplorer.ReservationCoverageGroup{
+							group("t3.nano", "100"),
+							group("t3.micro", "50"),
+						},
+					},
+				},
+				NextPageToken: aws.String("1"),
 			},
-			Coverage: &costexplorer.Coverage{
-				CoverageHours: &costexplorer.CoverageHours{
-					OnDemandHours:           aws.String("24"),
-					ReservedHours:           aws.String("24"),
-					TotalRunningHours:       aws.String("48"),
-					CoverageHoursPercentage: aws.String("50"),
+			{
+				CoveragesByTime: []*costexplorer.CoverageByTime{
+					{
+						TimePeriod: &costexplorer.DateInterval{
+							Start: aws.String("2019-12-20"),
+							End:   aws.String("2019-12-21"),
+						},
+						Groups: []*costexplorer.ReservationCoverageGroup{
+							group("t3.small", "0"),
+						},
+					},
+					{
+						TimePeriod: &costexplorer.DateInterval{
+							Start: aws.String("2019-12-21"),
+							End:   aws.String("2019-12-22"),
+						},
+						Groups: []*costexplorer.ReservationCoverageGroup{
+							group("t3.nano", "80"),
+						},
+					},
 				},
 			},
 		},
+	})
+
+	coverages, err := m.FetchRICoverage("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-22", "DAILY")
+	if err != nil {
+		t.Error(err)
 	}
 
-	if diff := cmp.Diff(expected, costCoverages); diff != "" {
+	day1 := time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2019, 12, 21, 0, 0, 0, 0, time.UTC)
+	expected := []*RICoverage{
+		{Start: day1, Attributes: map[string]string{"instanceType": "t3.nano", "region": endpoints.ApNortheast1RegionID}, CoverageHoursPercentage: 100},
+		{Start: day1, Attributes: map[string]string{"instanceType": "t3.micro", "region": endpoints.ApNortheast1RegionID}, CoverageHoursPercentage: 50},
+		{Start: day1, Attributes: map[string]string{"instanceType": "t3.small", "region": endpoints.ApNortheast1RegionID}, CoverageHoursPercentage: 0},
+		{Start: day2, Attributes: map[string]string{"instanceType": "t3.nano", "region": endpoints.ApNortheast1RegionID}, CoverageHoursPercentage: 80},
+	}
+	if diff := cmp.Diff(expected, coverages); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 連結アカウントごとの RI Coverage を取得する
+func TestFetchRICoverageByLinkedAccount(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		reservationCoverageOutputs: []*costexplorer.GetReservationCoverageOutput{
+			{
+				CoveragesByTime: []*costexplorer.CoverageByTime{
+					{
+						TimePeriod: &costexplorer.DateInterval{
+							Start: aws.String("2019-12-20"),
+							End:   aws.String("2019-12-21"),
+						},
+						Groups: []*costexplorer.ReservationCoverageGroup{
+							{
+								Attributes: map[string]*string{
+									"linkedAccount": aws.String("111111111111"),
+								},
+								Coverage: &costexplorer.Coverage{
+									CoverageHours: &costexplorer.CoverageHours{
+										CoverageHoursPercentage: aws.String("75"),
+										OnDemandHours:           aws.String("6"),
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+
+	coverages, err := m.FetchRICoverageByLinkedAccount("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-21", "DAILY")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*RICoverage{
+		{
+			Start:                   time.Date(2019, 12, 20, 0, 0, 0, 0, time.UTC),
+			Attributes:              map[string]string{"linkedAccount": "111111111111"},
+			LinkedAccount:           "111111111111",
+			CoverageHoursPercentage: 75,
+			OnDemandHours:           6,
+		},
+	}
+	if diff := cmp.Diff(expected, coverages); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 指定したディメンションで RI Coverage をグループ化する
+func TestFetchRICoverageWithGroupBy(t *testing.T) {
+	client := &mockCostExplorerClient{
+		reservationCoverageOutputs: []*costexplorer.GetReservationCoverageOutput{{}},
+	}
+	m := NewCostexplorer(client, WithCoverageGroupBy("REGION", "PLATFORM"))
+
+	if _, err := m.FetchRICoverage("Amazon Elastic Compute Cloud - Compute", "2019-12-20", "2019-12-21", "DAILY"); err != nil {
+		t.Error(err)
+	}
+
+	expected := []*costexplorer.GroupDefinition{
+		{Type: aws.String("DIMENSION"), Key: aws.String("REGION")},
+		{Type: aws.String("DIMENSION"), Key: aws.String("PLATFORM")},
+	}
+	if diff := cmp.Diff(expected, client.reservationCoverageInput.GroupBy); diff != "" {
 		t.Errorf("wrong result : %s", diff)
 	}
 }
 
-func TestFetchRICoveragePercentageFailed(t *testing.T) {
+func TestFetchRICoverageFailed(t *testing.T) {
 	m := NewCostexplorer(&mockCostExplorerClient{
-		reservationCoverageOutput: &costexplorer.GetReservationCoverageOutput{},
-		Error:                     errors.New("error occured"),
+		Error: errors.New("error occured"),
 	})
 
 	service := "Amazon Elastic Compute Cloud - Compute"
@@ -253,7 +754,7 @@ func TestFetchRICoveragePercentageFailed(t *testing.T) {
 	now := time.Now()
 	startDay := now.AddDate(0, 0, -2).Format("2006-01-02")
 	endDay := now.Format("2006-01-02")
-	_, err := m.FetchRICoveragePercentage(service, startDay, endDay)
+	_, err := m.FetchRICoverage(service, startDay, endDay, "DAILY")
 	if err == nil {
 		t.Error("wrong result : err is nil")
 	}
@@ -0,0 +1,62 @@
+package awsapi
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+const (
+	// dynamoDBKeyAttribute : name of the partition key of the table, a string
+	dynamoDBKeyAttribute = "key"
+	// dynamoDBValueAttribute : name of the attribute holding the value, a binary
+	dynamoDBValueAttribute = "value"
+)
+
+// DynamoDBIface : dynamodb interface
+type DynamoDBIface interface {
+	GetValue(table, key string) ([]byte, bool, error)
+	PutValue(table, key string, value []byte) error
+}
+
+// DynamoDBInstance : dynamodb instance
+type DynamoDBInstance struct {
+	client dynamodbiface.DynamoDBAPI
+}
+
+// NewDynamoDB ... generate new dynamodb client
+func NewDynamoDB(client dynamodbiface.DynamoDBAPI) DynamoDBIface {
+	return &DynamoDBInstance{
+		client: client,
+	}
+}
+
+// GetValue ... get the value of the item of the key, false if the item does not exist
+func (d *DynamoDBInstance) GetValue(table, key string) ([]byte, bool, error) {
+	r, err := d.client.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(table),
+		Key: map[string]*dynamodb.AttributeValue{
+			dynamoDBKeyAttribute: {S: aws.String(key)},
+		},
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	v, ok := r.Item[dynamoDBValueAttribute]
+	if !ok {
+		return nil, false, nil
+	}
+	return v.B, true, nil
+}
+
+// PutValue ... put the value as the item of the key, overwriting the existing one
+func (d *DynamoDBInstance) PutValue(table, key string, value []byte) error {
+	_, err := d.client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(table),
+		Item: map[string]*dynamodb.AttributeValue{
+			dynamoDBKeyAttribute:   {S: aws.String(key)},
+			dynamoDBValueAttribute: {B: value},
+		},
+	})
+	return err
+}
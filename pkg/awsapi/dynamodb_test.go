@@ -0,0 +1,69 @@
+package awsapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockDynamoDBClient struct {
+	dynamodbiface.DynamoDBAPI
+
+	// Items : items keyed by "table/key"
+	Items map[string]map[string]*dynamodb.AttributeValue
+	Error error
+}
+
+func (m *mockDynamoDBClient) GetItem(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	item := m.Items[aws.StringValue(input.TableName)+"/"+aws.StringValue(input.Key["key"].S)]
+	return &dynamodb.GetItemOutput{Item: item}, nil
+}
+
+func (m *mockDynamoDBClient) PutItem(input *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	m.Items[aws.StringValue(input.TableName)+"/"+aws.StringValue(input.Item["key"].S)] = input.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestPutAndGetValue(t *testing.T) {
+	m := NewDynamoDB(&mockDynamoDBClient{Items: map[string]map[string]*dynamodb.AttributeValue{}})
+
+	if err := m.PutValue("cache", "FetchRIUtilization/a", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := m.GetValue("cache", "FetchRIUtilization/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("wrong result : the item is not found")
+	}
+	if diff := cmp.Diff("[]", string(v)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+
+	// 存在しないアイテムはエラーにならない
+	if _, ok, err := m.GetValue("cache", "FetchRIUtilization/b"); ok || err != nil {
+		t.Errorf("wrong result : %v, %v", ok, err)
+	}
+}
+
+func TestGetValueFailed(t *testing.T) {
+	m := NewDynamoDB(&mockDynamoDBClient{Error: errors.New("ResourceNotFoundException")})
+
+	if _, _, err := m.GetValue("cache", "FetchRIUtilization/a"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+	if err := m.PutValue("cache", "FetchRIUtilization/a", []byte("[]")); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
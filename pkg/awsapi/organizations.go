@@ -0,0 +1,39 @@
+package awsapi
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
+)
+
+// OrganizationsIface : organizations interface
+type OrganizationsIface interface {
+	ListAccountNames() (map[string]string, error)
+}
+
+// OrganizationsInstance : organizations instance
+type OrganizationsInstance struct {
+	client organizationsiface.OrganizationsAPI
+}
+
+// NewOrganizations ... generate new organizations client
+func NewOrganizations(client organizationsiface.OrganizationsAPI) OrganizationsIface {
+	return &OrganizationsInstance{
+		client: client,
+	}
+}
+
+// ListAccountNames ... names of the accounts of the organization keyed by account ID
+func (o *OrganizationsInstance) ListAccountNames() (map[string]string, error) {
+	names := map[string]string{}
+	err := o.client.ListAccountsPages(&organizations.ListAccountsInput{}, func(r *organizations.ListAccountsOutput, lastPage bool) bool {
+		for _, a := range r.Accounts {
+			names[aws.StringValue(a.Id)] = aws.StringValue(a.Name)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return names, nil
+}
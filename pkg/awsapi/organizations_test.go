@@ -0,0 +1,75 @@
+package awsapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/organizations"
+	"github.com/aws/aws-sdk-go/service/organizations/organizationsiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockOrganizationsClient struct {
+	organizationsiface.OrganizationsAPI
+
+	// pages of the responses
+	Outputs []*organizations.ListAccountsOutput
+	Error   error
+}
+
+func (m *mockOrganizationsClient) ListAccountsPages(input *organizations.ListAccountsInput, fn func(*organizations.ListAccountsOutput, bool) bool) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	for i, o := range m.Outputs {
+		if !fn(o, i == len(m.Outputs)-1) {
+			break
+		}
+	}
+	return nil
+}
+
+// 全ページのアカウント名をアカウント ID ごとに取得する
+func TestListAccountNames(t *testing.T) {
+	m := NewOrganizations(&mockOrganizationsClient{
+		Outputs: []*organizations.ListAccountsOutput{
+			{
+				Accounts: []*organizations.Account{
+					{Id: aws.String("111111111111"), Name: aws.String("production")},
+					{Id: aws.String("222222222222"), Name: aws.String("staging")},
+				},
+				NextToken: aws.String("next"),
+			},
+			{
+				Accounts: []*organizations.Account{
+					{Id: aws.String("333333333333"), Name: aws.String("sandbox")},
+				},
+			},
+		},
+	})
+
+	names, err := m.ListAccountNames()
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := map[string]string{
+		"111111111111": "production",
+		"222222222222": "staging",
+		"333333333333": "sandbox",
+	}
+	if diff := cmp.Diff(expected, names); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestListAccountNamesFailed(t *testing.T) {
+	m := NewOrganizations(&mockOrganizationsClient{
+		Error: errors.New("AWSOrganizationsNotInUseException"),
+	})
+
+	if _, err := m.ListAccountNames(); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
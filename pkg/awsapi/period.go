@@ -0,0 +1,48 @@
+package awsapi
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/pkg/errors"
+)
+
+// dateLayout : layout of the days of Cost Explorer time periods
+const dateLayout = "2006-01-02"
+
+// Period : time period of Cost Explorer from Start to End (exclusive)
+type Period struct {
+	Start string
+	End   string
+}
+
+// Periods ... time periods from startDay to endDay (exclusive), each day or calendar month by the granularity
+//
+// Months are clipped to the range, as Cost Explorer returns the time periods of a request.
+func Periods(startDay, endDay, granularity string) ([]Period, error) {
+	s, err := time.Parse(dateLayout, startDay)
+	if err != nil {
+		return nil, errors.Wrap(err, "on time.Parse of start")
+	}
+	e, err := time.Parse(dateLayout, endDay)
+	if err != nil {
+		return nil, errors.Wrap(err, "on time.Parse of end")
+	}
+
+	periods := []Period{}
+	for d := s; d.Before(e); {
+		next := d.AddDate(0, 0, 1)
+		if granularity == costexplorer.GranularityMonthly {
+			next = time.Date(d.Year(), d.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		}
+		if next.After(e) {
+			next = e
+		}
+		periods = append(periods, Period{
+			Start: d.Format(dateLayout),
+			End:   next.Format(dateLayout),
+		})
+		d = next
+	}
+	return periods, nil
+}
@@ -0,0 +1,59 @@
+package awsapi
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestPeriods(t *testing.T) {
+	tests := []struct {
+		name        string
+		start       string
+		end         string
+		granularity string
+		expected    []Period
+		wantErr     bool
+	}{
+		{
+			name:        "daily",
+			start:       "2020-02-28",
+			end:         "2020-03-01",
+			granularity: "DAILY",
+			expected:    []Period{{"2020-02-28", "2020-02-29"}, {"2020-02-29", "2020-03-01"}},
+		},
+		{
+			// 月の途中で始まり終わる期間はその範囲で区切る
+			name:        "monthly",
+			start:       "2020-01-15",
+			end:         "2020-03-10",
+			granularity: "MONTHLY",
+			expected:    []Period{{"2020-01-15", "2020-02-01"}, {"2020-02-01", "2020-03-01"}, {"2020-03-01", "2020-03-10"}},
+		},
+		{
+			name:        "empty",
+			start:       "2020-03-01",
+			end:         "2020-03-01",
+			granularity: "DAILY",
+			expected:    []Period{},
+		},
+		{
+			name:        "invalid date",
+			start:       "2020/03/01",
+			end:         "2020-03-02",
+			granularity: "DAILY",
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := Periods(tt.start, tt.end, tt.granularity)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Periods() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.expected, actual); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package awsapi
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimiter : limiter of the rate of API requests shared by clients running concurrently
+type RateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	// next : time the next request is allowed
+	next time.Time
+	// sleep : wait for the duration, replaced in tests
+	sleep func(time.Duration)
+}
+
+// NewRateLimiter ... generate a limiter allowing requests at the rate, no limit if the rate is not positive
+func NewRateLimiter(requestsPerSecond float64) *RateLimiter {
+	if requestsPerSecond <= 0 {
+		return nil
+	}
+	return &RateLimiter{
+		interval: time.Duration(float64(time.Second) / requestsPerSecond),
+		sleep:    time.Sleep,
+	}
+}
+
+// Wait ... block until the next request is allowed, a nil limiter does not block
+func (l *RateLimiter) Wait() {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	wait := l.next.Sub(now)
+	l.next = l.next.Add(l.interval)
+	l.mu.Unlock()
+
+	if wait > 0 {
+		l.sleep(wait)
+	}
+}
@@ -0,0 +1,43 @@
+package awsapi
+
+import (
+	"testing"
+	"time"
+)
+
+// 連続したリクエストは間隔を空けて待たされる
+func TestRateLimiterWait(t *testing.T) {
+	l := NewRateLimiter(10)
+	waits := []time.Duration{}
+	l.sleep = func(d time.Duration) {
+		waits = append(waits, d)
+	}
+
+	for i := 0; i < 3; i++ {
+		l.Wait()
+	}
+
+	if len(waits) != 2 {
+		t.Fatalf("wrong result : waited %d times", len(waits))
+	}
+	for i, expected := range []time.Duration{100 * time.Millisecond, 200 * time.Millisecond} {
+		if waits[i] > expected || waits[i] < expected-50*time.Millisecond {
+			t.Errorf("wrong result : waited %s instead of %s", waits[i], expected)
+		}
+	}
+}
+
+// レートが指定されなければ待たない
+func TestRateLimiterUnlimited(t *testing.T) {
+	l := NewRateLimiter(0)
+	if l != nil {
+		t.Fatalf("wrong result : %v", l)
+	}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		l.Wait()
+	}
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Errorf("wrong result : waited %s", d)
+	}
+}
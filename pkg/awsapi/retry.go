@@ -0,0 +1,90 @@
+package awsapi
+
+import (
+	"errors"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/cenkalti/backoff"
+)
+
+const (
+	// retryInitialInterval : interval before the first retry, randomized by ±50% and doubled on every retry
+	retryInitialInterval = time.Second
+	// retryMaxInterval : upper limit of the interval between retries
+	retryMaxInterval = 30 * time.Second
+
+	// errCodeValidation : error code of invalid requests, e.g. a start date after the end date
+	errCodeValidation = "ValidationException"
+)
+
+// WithRetry ... retry a request failing with throttling or a server error up to maxRetries times with jittered exponential backoff
+func WithRetry(maxRetries int) CostexplorerOption {
+	return func(c *CostexplorerInstance) {
+		c.maxRetries = maxRetries
+	}
+}
+
+// newJitteredBackOff ... exponential backoff whose intervals are randomized
+func newJitteredBackOff() backoff.BackOff {
+	b := backoff.NewExponentialBackOff()
+	b.InitialInterval = retryInitialInterval
+	b.MaxInterval = retryMaxInterval
+	// retries are limited by the number instead of the time
+	b.MaxElapsedTime = 0
+	return b
+}
+
+// call ... call the API after waiting for the limiter, retrying it while the error is retryable
+//
+// Retries stop once the context is done or its deadline comes before the next retry,
+// returning the last error instead of sleeping past the deadline.
+// The retryer of the SDK is expected to be disabled, so that a request is sent at most maxRetries+1 times.
+func (c *CostexplorerInstance) call(fn func() error) error {
+	if c.maxRetries < 1 {
+		c.limiter.Wait()
+		return fn()
+	}
+
+	return backoff.Retry(func() error {
+		c.limiter.Wait()
+		err := fn()
+		if err != nil && !retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(backoff.WithMaxRetries(c.newBackOff(), uint64(c.maxRetries)), c.ctx))
+}
+
+// retryable ... whether the error of Cost Explorer is temporary
+//
+// Missing data, an expired bill and an invalid request such as an invalid date fail again,
+// while throttling including LimitExceededException and server errors may succeed later.
+func retryable(err error) bool {
+	aerr, ok := err.(awserr.Error)
+	if !ok {
+		return false
+	}
+	switch aerr.Code() {
+	case costexplorer.ErrCodeLimitExceededException:
+		return true
+	case costexplorer.ErrCodeDataUnavailableException,
+		costexplorer.ErrCodeBillExpirationException,
+		costexplorer.ErrCodeInvalidNextTokenException,
+		costexplorer.ErrCodeRequestChangedException,
+		errCodeValidation:
+		return false
+	}
+	if rerr, ok := err.(awserr.RequestFailure); ok && rerr.StatusCode() >= 500 {
+		return true
+	}
+	return request.IsErrorThrottle(err) || request.IsErrorRetryable(err)
+}
+
+// IsDataUnavailable ... whether the error, even if wrapped, is DataUnavailableException returned while Cost Explorer has no data of the period yet
+func IsDataUnavailable(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == costexplorer.ErrCodeDataUnavailableException
+}
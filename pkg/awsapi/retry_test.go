@@ -0,0 +1,213 @@
+package awsapi
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/cenkalti/backoff"
+	"github.com/google/go-cmp/cmp"
+)
+
+// noBackOff ... retry without waiting
+func noBackOff() backoff.BackOff {
+	return &backoff.ZeroBackOff{}
+}
+
+func TestFetchRIUtilizationRetried(t *testing.T) {
+	limitExceeded := awserr.New(costexplorer.ErrCodeLimitExceededException, "Rate exceeded", nil)
+	tests := []struct {
+		name       string
+		errors     []error
+		maxRetries int
+		attempts   int
+		wantErr    bool
+	}{
+		{
+			name:       "throttled once",
+			errors:     []error{limitExceeded},
+			maxRetries: 3,
+			attempts:   2,
+		},
+		{
+			name:       "server error",
+			errors:     []error{awserr.NewRequestFailure(awserr.New("InternalServerError", "", nil), 500, "")},
+			maxRetries: 3,
+			attempts:   2,
+		},
+		{
+			// リトライ回数を超えたらエラーを返す
+			name:       "throttled persistently",
+			errors:     []error{limitExceeded, limitExceeded, limitExceeded, limitExceeded},
+			maxRetries: 3,
+			attempts:   4,
+			wantErr:    true,
+		},
+		{
+			// リトライしない設定ではスロットリングでも 1 度だけ
+			name:     "retry disabled",
+			errors:   []error{limitExceeded},
+			attempts: 1,
+			wantErr:  true,
+		},
+		{
+			name:       "data unavailable",
+			errors:     []error{awserr.New(costexplorer.ErrCodeDataUnavailableException, "", nil)},
+			maxRetries: 3,
+			attempts:   1,
+			wantErr:    true,
+		},
+		{
+			name:       "bill expired",
+			errors:     []error{awserr.New(costexplorer.ErrCodeBillExpirationException, "", nil)},
+			maxRetries: 3,
+			attempts:   1,
+			wantErr:    true,
+		},
+		{
+			name:       "invalid date",
+			errors:     []error{awserr.New("ValidationException", "Start date (and hour) should be before end date (and hour)", nil)},
+			maxRetries: 3,
+			attempts:   1,
+			wantErr:    true,
+		},
+		{
+			name:       "unknown error",
+			errors:     []error{errors.New("error occured")},
+			maxRetries: 3,
+			attempts:   1,
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCostExplorerClient{
+				reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{}},
+				errors:                        tt.errors,
+			}
+			c := NewCostexplorer(client, WithRetry(tt.maxRetries)).(*CostexplorerInstance)
+			c.newBackOff = noBackOff
+
+			_, err := c.FetchRIUtilization("Amazon Redshift", "2019-12-20", "2019-12-23", "DAILY")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchRIUtilization() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.attempts, client.attempts); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// ページごとにリトライし、取得済みのページはリクエストし直さない
+func TestFetchRICoverageRetriedPerPage(t *testing.T) {
+	limitExceeded := awserr.New(costexplorer.ErrCodeLimitExceededException, "Rate exceeded", nil)
+	client := &mockCostExplorerClient{
+		reservationCoverageOutputs: []*costexplorer.GetReservationCoverageOutput{
+			{NextPageToken: aws.String("1")},
+			{},
+		},
+		errors: []error{nil, limitExceeded, limitExceeded},
+	}
+	c := NewCostexplorer(client, WithRetry(3)).(*CostexplorerInstance)
+	c.newBackOff = noBackOff
+
+	if _, err := c.FetchRICoverage("Amazon Redshift", "2019-12-20", "2019-12-23", "DAILY"); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(4, client.attempts); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// リトライの前にも limiter で待つ
+func TestRetryWaitsForLimiter(t *testing.T) {
+	limiter := NewRateLimiter(1)
+	waits := 0
+	limiter.sleep = func(time.Duration) {
+		waits++
+	}
+	client := &mockCostExplorerClient{
+		reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{}},
+		errors:                        []error{awserr.New(costexplorer.ErrCodeLimitExceededException, "Rate exceeded", nil)},
+	}
+	c := NewCostexplorer(client, WithRetry(3), WithRateLimiter(limiter)).(*CostexplorerInstance)
+	c.newBackOff = noBackOff
+
+	if _, err := c.FetchRIUtilization("Amazon Redshift", "2019-12-20", "2019-12-23", "DAILY"); err != nil {
+		t.Fatal(err)
+	}
+	// 最初のリクエストは待たない
+	if waits != 1 {
+		t.Errorf("wrong result : waited %d times", waits)
+	}
+}
+
+// context が終わったらリトライを止める
+func TestRetryStopsWithContext(t *testing.T) {
+	limitExceeded := awserr.New(costexplorer.ErrCodeLimitExceededException, "Rate exceeded", nil)
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	// 次のリトライまで待つと期限を過ぎる
+	expiring, cancelExpiring := context.WithTimeout(context.Background(), time.Minute)
+	defer cancelExpiring()
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{name: "canceled", ctx: canceled},
+		{name: "deadline before the next retry", ctx: expiring},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCostExplorerClient{
+				reservationUtilizationOutputs: []*costexplorer.GetReservationUtilizationOutput{{}},
+				errors:                        []error{limitExceeded, limitExceeded, limitExceeded},
+			}
+			c := NewCostexplorer(client, WithRetry(3), WithContext(tt.ctx)).(*CostexplorerInstance)
+			c.newBackOff = func() backoff.BackOff {
+				return backoff.NewConstantBackOff(time.Hour)
+			}
+
+			_, err := c.FetchRIUtilization("Amazon Redshift", "2019-12-20", "2019-12-23", "DAILY")
+			if !errors.Is(err, limitExceeded) {
+				t.Fatalf("FetchRIUtilization() error = %v", err)
+			}
+			if diff := cmp.Diff(1, client.attempts); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+			// リクエストにも同じ context を渡す
+			if client.ctx != tt.ctx {
+				t.Errorf("wrong result : %v", client.ctx)
+			}
+		})
+	}
+}
+
+func TestIsDataUnavailable(t *testing.T) {
+	unavailable := awserr.New(costexplorer.ErrCodeDataUnavailableException, "data is not available", nil)
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "DataUnavailableException", err: unavailable, expected: true},
+		// 呼び出し元でラップされていても判定できる
+		{name: "wrapped", err: fmt.Errorf("on FetchRIUtilization: %w", unavailable), expected: true},
+		{name: "other error code", err: awserr.New(costexplorer.ErrCodeLimitExceededException, "rate exceeded", nil), expected: false},
+		{name: "not an AWS error", err: errors.New("DataUnavailableException"), expected: false},
+		{name: "nil", err: nil, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := IsDataUnavailable(tt.err); actual != tt.expected {
+				t.Errorf("wrong result : %v", actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,72 @@
+package awsapi
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// S3Iface : s3 interface
+type S3Iface interface {
+	GetObject(bucket, key string) ([]byte, error)
+	PutObject(bucket, key string, body []byte) error
+}
+
+// S3Instance : s3 instance
+type S3Instance struct {
+	client s3iface.S3API
+}
+
+// NewS3 ... generate new s3 client
+func NewS3(client s3iface.S3API) S3Iface {
+	return &S3Instance{
+		client: client,
+	}
+}
+
+// GetObject ... get the content of an object
+func (s *S3Instance) GetObject(bucket, key string) ([]byte, error) {
+	r, err := s.client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer r.Body.Close()
+
+	return ioutil.ReadAll(r.Body)
+}
+
+// PutObject ... put the content as an object
+func (s *S3Instance) PutObject(bucket, key string, body []byte) error {
+	_, err := s.client.PutObject(&s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(body),
+	})
+	return err
+}
+
+// IsNoSuchKey ... whether the error, even if wrapped, is NoSuchKey returned for a missing object
+func IsNoSuchKey(err error) bool {
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == s3.ErrCodeNoSuchKey
+}
+
+// IsAccessDenied ... whether the error, even if wrapped, is 403 AccessDenied
+//
+// S3 answers 403 instead of NoSuchKey for a missing object unless s3:ListBucket is granted.
+func IsAccessDenied(err error) bool {
+	var rerr awserr.RequestFailure
+	if errors.As(err, &rerr) && rerr.StatusCode() == 403 {
+		return true
+	}
+	var aerr awserr.Error
+	return errors.As(err, &aerr) && aerr.Code() == "AccessDenied"
+}
@@ -0,0 +1,128 @@
+package awsapi
+
+import (
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockS3Client struct {
+	s3iface.S3API
+
+	Objects map[string]string
+	Error   error
+}
+
+func (m *mockS3Client) GetObject(input *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	body, ok := m.Objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return &s3.GetObjectOutput{
+		Body: ioutil.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+func (m *mockS3Client) PutObject(input *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	body, err := ioutil.ReadAll(input.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.Objects[aws.StringValue(input.Bucket)+"/"+aws.StringValue(input.Key)] = string(body)
+	return &s3.PutObjectOutput{}, nil
+}
+
+func TestGetObject(t *testing.T) {
+	m := NewS3(&mockS3Client{
+		Objects: map[string]string{
+			"bucket/config.yaml": "sinks: [datadog]",
+		},
+	})
+
+	b, err := m.GetObject("bucket", "config.yaml")
+	if err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff("sinks: [datadog]", string(b)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestGetObjectFailed(t *testing.T) {
+	m := NewS3(&mockS3Client{
+		Error: errors.New("AccessDenied"),
+	})
+
+	if _, err := m.GetObject("bucket", "config.yaml"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+func TestPutObject(t *testing.T) {
+	client := &mockS3Client{Objects: map[string]string{}}
+	m := NewS3(client)
+
+	if err := m.PutObject("bucket", "cache/key.json", []byte("[]")); err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(map[string]string{"bucket/cache/key.json": "[]"}, client.Objects); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestPutObjectFailed(t *testing.T) {
+	m := NewS3(&mockS3Client{
+		Error: errors.New("AccessDenied"),
+	})
+
+	if err := m.PutObject("bucket", "cache/key.json", []byte("[]")); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+// 存在しないオブジェクトは NoSuchKey として判定できる
+func TestIsNoSuchKey(t *testing.T) {
+	m := NewS3(&mockS3Client{Objects: map[string]string{}})
+
+	_, err := m.GetObject("bucket", "missing.json")
+	if !IsNoSuchKey(err) {
+		t.Errorf("wrong result : %v", err)
+	}
+	if IsNoSuchKey(errors.New("AccessDenied")) {
+		t.Error("wrong result : AccessDenied is NoSuchKey")
+	}
+}
+
+func TestIsAccessDenied(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{name: "403", err: awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, ""), expected: true},
+		{name: "AccessDenied", err: awserr.New("AccessDenied", "Access Denied", nil), expected: true},
+		{name: "NoSuchKey", err: awserr.NewRequestFailure(awserr.New(s3.ErrCodeNoSuchKey, "", nil), 404, ""), expected: false},
+		{name: "not an AWS error", err: errors.New("AccessDenied"), expected: false},
+		{name: "nil", err: nil, expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if actual := IsAccessDenied(tt.err); actual != tt.expected {
+				t.Errorf("wrong result : %v", actual)
+			}
+		})
+	}
+}
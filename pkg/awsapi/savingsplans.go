@@ -0,0 +1,165 @@
+package awsapi
+
+import (
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+)
+
+// SavingsPlansUtilization : utilization of Savings Plans
+type SavingsPlansUtilization struct {
+	// Start : start of the time period of the utilization
+	Start time.Time
+	// SavingsPlanArn : empty for the utilization of all Savings Plans
+	SavingsPlanArn        string
+	UtilizationPercentage float64
+	TotalCommitment       float64
+	UsedCommitment        float64
+	UnusedCommitment      float64
+}
+
+func newSavingsPlansUtilization(start time.Time, arn string, u *costexplorer.SavingsPlansUtilization) *SavingsPlansUtilization {
+	return &SavingsPlansUtilization{
+		Start:                 start,
+		SavingsPlanArn:        arn,
+		UtilizationPercentage: parseFloat(u.UtilizationPercentage),
+		TotalCommitment:       parseFloat(u.TotalCommitment),
+		UsedCommitment:        parseFloat(u.UsedCommitment),
+		UnusedCommitment:      parseFloat(u.UnusedCommitment),
+	}
+}
+
+// SavingsPlansCoverage : Savings Plans coverage of a group
+type SavingsPlansCoverage struct {
+	// Start : start of the time period of the coverage
+	Start time.Time
+	// Attributes : attributes of the group, e.g. SERVICE
+	Attributes                 map[string]string
+	CoveragePercentage         float64
+	OnDemandCost               float64
+	SpendCoveredBySavingsPlans float64
+	TotalCost                  float64
+}
+
+func newSavingsPlansCoverage(c *costexplorer.SavingsPlansCoverage) *SavingsPlansCoverage {
+	attributes := map[string]string{}
+	for k, v := range c.Attributes {
+		attributes[k] = aws.StringValue(v)
+	}
+
+	cov := &SavingsPlansCoverage{
+		Start:      periodStart(c.TimePeriod),
+		Attributes: attributes,
+	}
+	if d := c.Coverage; d != nil {
+		cov.CoveragePercentage = parseFloat(d.CoveragePercentage)
+		cov.OnDemandCost = parseFloat(d.OnDemandCost)
+		cov.SpendCoveredBySavingsPlans = parseFloat(d.SpendCoveredBySavingsPlans)
+		cov.TotalCost = parseFloat(d.TotalCost)
+	}
+	return cov
+}
+
+// FetchSavingsPlansUtilization ... fetch Savings Plans Utilization of each time period, empty if you do not have any Savings Plans
+func (c *CostexplorerInstance) FetchSavingsPlansUtilization(startDay, endDay, granularity string) ([]*SavingsPlansUtilization, error) {
+	input := &costexplorer.GetSavingsPlansUtilizationInput{
+		Granularity: aws.String(granularity),
+		TimePeriod: &costexplorer.DateInterval{
+			Start: aws.String(startDay),
+			End:   aws.String(endDay),
+		},
+		Filter: c.accountFilter(),
+	}
+	var r *costexplorer.GetSavingsPlansUtilizationOutput
+	err := c.call(func() (err error) {
+		r, err = c.client.GetSavingsPlansUtilizationWithContext(c.ctx, input)
+		return err
+	})
+	if err != nil {
+		return []*SavingsPlansUtilization{}, err
+	}
+
+	utils := []*SavingsPlansUtilization{}
+	for _, u := range r.SavingsPlansUtilizationsByTime {
+		if u.Utilization == nil {
+			continue
+		}
+		utils = append(utils, newSavingsPlansUtilization(periodStart(u.TimePeriod), "", u.Utilization))
+	}
+	return utils, nil
+}
+
+// FetchSavingsPlansUtilizationDetails ... fetch Savings Plans Utilization of each plan
+//
+// The utilization covers the whole period, stamped at the start of the period.
+func (c *CostexplorerInstance) FetchSavingsPlansUtilizationDetails(startDay, endDay string) ([]*SavingsPlansUtilization, error) {
+	input := &costexplorer.GetSavingsPlansUtilizationDetailsInput{
+		TimePeriod: &costexplorer.DateInterval{
+			Start: aws.String(startDay),
+			End:   aws.String(endDay),
+		},
+		Filter: c.accountFilter(),
+	}
+
+	details := []*SavingsPlansUtilization{}
+	for {
+		var r *costexplorer.GetSavingsPlansUtilizationDetailsOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetSavingsPlansUtilizationDetailsWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []*SavingsPlansUtilization{}, err
+		}
+		for _, d := range r.SavingsPlansUtilizationDetails {
+			if d.Utilization == nil {
+				continue
+			}
+			details = append(details, newSavingsPlansUtilization(periodStart(r.TimePeriod), aws.StringValue(d.SavingsPlanArn), d.Utilization))
+		}
+
+		if aws.StringValue(r.NextToken) == "" {
+			return details, nil
+		}
+		input.NextToken = r.NextToken
+	}
+}
+
+// FetchSavingsPlansCoverage ... fetch Savings Plans Coverage of each service in each time period
+func (c *CostexplorerInstance) FetchSavingsPlansCoverage(startDay, endDay, granularity string) ([]*SavingsPlansCoverage, error) {
+	input := &costexplorer.GetSavingsPlansCoverageInput{
+		Granularity: aws.String(granularity),
+		TimePeriod: &costexplorer.DateInterval{
+			Start: aws.String(startDay),
+			End:   aws.String(endDay),
+		},
+		Filter: c.accountFilter(),
+		GroupBy: []*costexplorer.GroupDefinition{
+			{
+				Type: aws.String("DIMENSION"),
+				Key:  aws.String("SERVICE"),
+			},
+		},
+	}
+
+	coverages := []*SavingsPlansCoverage{}
+	for {
+		var r *costexplorer.GetSavingsPlansCoverageOutput
+		err := c.call(func() (err error) {
+			r, err = c.client.GetSavingsPlansCoverageWithContext(c.ctx, input)
+			return err
+		})
+		if err != nil {
+			return []*SavingsPlansCoverage{}, err
+		}
+		for _, cov := range r.SavingsPlansCoverages {
+			coverages = append(coverages, newSavingsPlansCoverage(cov))
+		}
+
+		if aws.StringValue(r.NextToken) == "" {
+			return coverages, nil
+		}
+		input.NextToken = r.NextToken
+	}
+}
@@ -0,0 +1,228 @@
+package awsapi
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/costexplorer"
+	"github.com/google/go-cmp/cmp"
+)
+
+// 正常に期間ごとの Savings Plans Utilization 取得
+func TestFetchSavingsPlansUtilizationSuccessfully(t *testing.T) {
+	utilization := func(start, end, pct string) *costexplorer.SavingsPlansUtilizationByTime {
+		return &costexplorer.SavingsPlansUtilizationByTime{
+			TimePeriod: &costexplorer.DateInterval{
+				Start: aws.String(start),
+				End:   aws.String(end),
+			},
+			Utilization: &costexplorer.SavingsPlansUtilization{
+				TotalCommitment:       aws.String("24"),
+				UnusedCommitment:      aws.String("6"),
+				UsedCommitment:        aws.String("18"),
+				UtilizationPercentage: aws.String(pct),
+			},
+		}
+	}
+	m := NewCostexplorer(&mockCostExplorerClient{
+		savingsPlansUtilizationOutput: &costexplorer.GetSavingsPlansUtilizationOutput{
+			SavingsPlansUtilizationsByTime: []*costexplorer.SavingsPlansUtilizationByTime{
+				utilization("2020-03-01", "2020-03-02", "75"),
+				utilization("2020-03-02", "2020-03-03", "50"),
+			},
+		},
+	})
+
+	utils, err := m.FetchSavingsPlansUtilization("2020-03-01", "2020-03-03", "DAILY")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*SavingsPlansUtilization{
+		{
+			Start:                 time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+			UtilizationPercentage: 75,
+			TotalCommitment:       24,
+			UsedCommitment:        18,
+			UnusedCommitment:      6,
+		},
+		{
+			Start:                 time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+			UtilizationPercentage: 50,
+			TotalCommitment:       24,
+			UsedCommitment:        18,
+			UnusedCommitment:      6,
+		},
+	}
+	if diff := cmp.Diff(expected, utils); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// Savings Plans を購入していないケースは空を返す
+func TestFetchSavingsPlansUtilizationNoSavingsPlans(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		savingsPlansUtilizationOutput: &costexplorer.GetSavingsPlansUtilizationOutput{
+			SavingsPlansUtilizationsByTime: []*costexplorer.SavingsPlansUtilizationByTime{},
+		},
+	})
+
+	utils, err := m.FetchSavingsPlansUtilization("2020-03-01", "2020-03-03", "DAILY")
+	if err != nil {
+		t.Error(err)
+	}
+	if len(utils) != 0 {
+		t.Errorf("wrong result : %v", utils)
+	}
+}
+
+func TestFetchSavingsPlansUtilizationFailed(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		Error: errors.New("error occured"),
+	})
+
+	if _, err := m.FetchSavingsPlansUtilization("2020-03-01", "2020-03-03", "DAILY"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+// ページングされた全ての Savings Plans の Utilization を取得する
+func TestFetchSavingsPlansUtilizationDetails(t *testing.T) {
+	detail := func(arn, pct string) *costexplorer.SavingsPlansUtilizationDetail {
+		return &costexplorer.SavingsPlansUtilizationDetail{
+			SavingsPlanArn: aws.String(arn),
+			Utilization: &costexplorer.SavingsPlansUtilization{
+				UtilizationPercentage: aws.String(pct),
+			},
+		}
+	}
+	period := &costexplorer.DateInterval{
+		Start: aws.String("2020-03-01"),
+		End:   aws.String("2020-03-03"),
+	}
+	m := NewCostexplorer(&mockCostExplorerClient{
+		savingsPlansUtilizationDetailsOutputs: []*costexplorer.GetSavingsPlansUtilizationDetailsOutput{
+			{
+				SavingsPlansUtilizationDetails: []*costexplorer.SavingsPlansUtilizationDetail{
+					detail("arn:aws:savingsplans::123456789012:savingsplan/a", "100"),
+				},
+				TimePeriod: period,
+				NextToken:  aws.String("1"),
+			},
+			{
+				SavingsPlansUtilizationDetails: []*costexplorer.SavingsPlansUtilizationDetail{
+					detail("arn:aws:savingsplans::123456789012:savingsplan/b", "50"),
+				},
+				TimePeriod: period,
+			},
+		},
+	})
+
+	details, err := m.FetchSavingsPlansUtilizationDetails("2020-03-01", "2020-03-03")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*SavingsPlansUtilization{
+		{
+			Start:                 time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+			SavingsPlanArn:        "arn:aws:savingsplans::123456789012:savingsplan/a",
+			UtilizationPercentage: 100,
+		},
+		{
+			Start:                 time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+			SavingsPlanArn:        "arn:aws:savingsplans::123456789012:savingsplan/b",
+			UtilizationPercentage: 50,
+		},
+	}
+	if diff := cmp.Diff(expected, details); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestFetchSavingsPlansUtilizationDetailsFailed(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		Error: errors.New("error occured"),
+	})
+
+	if _, err := m.FetchSavingsPlansUtilizationDetails("2020-03-01", "2020-03-03"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+// ページングされた全ての期間の Savings Plans Coverage を取得する
+func TestFetchSavingsPlansCoverage(t *testing.T) {
+	coverage := func(start, service, pct string) *costexplorer.SavingsPlansCoverage {
+		return &costexplorer.SavingsPlansCoverage{
+			Attributes: map[string]*string{
+				"SERVICE": aws.String(service),
+			},
+			Coverage: &costexplorer.SavingsPlansCoverageData{
+				CoveragePercentage: aws.String(pct),
+			},
+			TimePeriod: &costexplorer.DateInterval{
+				Start: aws.String(start),
+			},
+		}
+	}
+	m := NewCostexplorer(&mockCostExplorerClient{
+		savingsPlansCoverageOutputs: []*costexplorer.GetSavingsPlansCoverageOutput{
+			{
+				SavingsPlansCoverages: []*costexplorer.SavingsPlansCoverage{
+					coverage("2020-03-01", "Amazon Elastic Compute Cloud - Compute", "80"),
+					coverage("2020-03-02", "Amazon Elastic Compute Cloud - Compute", "70"),
+				},
+				NextToken: aws.String("1"),
+			},
+			{
+				SavingsPlansCoverages: []*costexplorer.SavingsPlansCoverage{
+					coverage("2020-03-01", "AWS Lambda", "10"),
+					coverage("2020-03-02", "AWS Lambda", "20"),
+				},
+			},
+		},
+	})
+
+	coverages, err := m.FetchSavingsPlansCoverage("2020-03-01", "2020-03-03", "DAILY")
+	if err != nil {
+		t.Error(err)
+	}
+
+	expected := []*SavingsPlansCoverage{
+		{
+			Start:              time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+			Attributes:         map[string]string{"SERVICE": "Amazon Elastic Compute Cloud - Compute"},
+			CoveragePercentage: 80,
+		},
+		{
+			Start:              time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+			Attributes:         map[string]string{"SERVICE": "Amazon Elastic Compute Cloud - Compute"},
+			CoveragePercentage: 70,
+		},
+		{
+			Start:              time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+			Attributes:         map[string]string{"SERVICE": "AWS Lambda"},
+			CoveragePercentage: 10,
+		},
+		{
+			Start:              time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+			Attributes:         map[string]string{"SERVICE": "AWS Lambda"},
+			CoveragePercentage: 20,
+		},
+	}
+	if diff := cmp.Diff(expected, coverages); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestFetchSavingsPlansCoverageFailed(t *testing.T) {
+	m := NewCostexplorer(&mockCostExplorerClient{
+		Error: errors.New("error occured"),
+	})
+
+	if _, err := m.FetchSavingsPlansCoverage("2020-03-01", "2020-03-03", "DAILY"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
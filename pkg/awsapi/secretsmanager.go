@@ -0,0 +1,40 @@
+package awsapi
+
+import (
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+)
+
+// SecretsManagerIface : secretsmanager interface
+type SecretsManagerIface interface {
+	GetSecretString(secretID string) (string, error)
+}
+
+// SecretsManagerInstance : secretsmanager instance
+type SecretsManagerInstance struct {
+	client secretsmanageriface.SecretsManagerAPI
+}
+
+// NewSecretsManager ... generate new secretsmanager client
+func NewSecretsManager(client secretsmanageriface.SecretsManagerAPI) SecretsManagerIface {
+	return &SecretsManagerInstance{
+		client: client,
+	}
+}
+
+// GetSecretString ... get the current version of the secret string, which follows the rotation
+func (s *SecretsManagerInstance) GetSecretString(secretID string) (string, error) {
+	r, err := s.client.GetSecretValue(&secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		return "", err
+	}
+	if r.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no secret string", secretID)
+	}
+	return aws.StringValue(r.SecretString), nil
+}
@@ -0,0 +1,63 @@
+package awsapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockSecretsManagerClient struct {
+	secretsmanageriface.SecretsManagerAPI
+
+	Output *secretsmanager.GetSecretValueOutput
+	Error  error
+}
+
+func (m *mockSecretsManagerClient) GetSecretValue(*secretsmanager.GetSecretValueInput) (*secretsmanager.GetSecretValueOutput, error) {
+	return m.Output, m.Error
+}
+
+func TestGetSecretString(t *testing.T) {
+	m := NewSecretsManager(&mockSecretsManagerClient{
+		Output: &secretsmanager.GetSecretValueOutput{
+			Name:         aws.String("datadog"),
+			SecretString: aws.String(`{"api_key":"hogehoge","app_key":"mogemoge"}`),
+		},
+	})
+
+	s, err := m.GetSecretString("datadog")
+	if err != nil {
+		t.Error(err)
+	}
+	if diff := cmp.Diff(`{"api_key":"hogehoge","app_key":"mogemoge"}`, s); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// バイナリの secret は扱わない
+func TestGetSecretStringBinary(t *testing.T) {
+	m := NewSecretsManager(&mockSecretsManagerClient{
+		Output: &secretsmanager.GetSecretValueOutput{
+			Name:         aws.String("datadog"),
+			SecretBinary: []byte("hogehoge"),
+		},
+	})
+
+	if _, err := m.GetSecretString("datadog"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+func TestGetSecretStringFailed(t *testing.T) {
+	m := NewSecretsManager(&mockSecretsManagerClient{
+		Error: errors.New("ResourceNotFoundException"),
+	})
+
+	if _, err := m.GetSecretString("datadog"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
@@ -1,14 +1,21 @@
 package awsapi
 
 import (
+	"fmt"
+	"strings"
+
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/ssm"
 	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
 )
 
+// maxParametersPerRequest : GetParameters accepts no more than 10 names per request
+const maxParametersPerRequest = 10
+
 // SSMIface : -
 type SSMIface interface {
 	GetSSMParameters(keys []string) (map[string]string, error)
+	GetSSMParametersByPath(path string) (map[string]string, error)
 }
 
 // SSMInstance : ssm instance
@@ -16,6 +23,15 @@ type SSMInstance struct {
 	client ssmiface.SSMAPI
 }
 
+// InvalidParametersError : error of the names which are not found in ssm parameter store
+type InvalidParametersError struct {
+	Names []string
+}
+
+func (e *InvalidParametersError) Error() string {
+	return fmt.Sprintf("invalid SSM parameters: %s", strings.Join(e.Names, ", "))
+}
+
 // NewSSMClient ... generate a new ssm client
 func NewSSMClient(client ssmiface.SSMAPI) SSMIface {
 	return &SSMInstance{
@@ -23,26 +39,57 @@ func NewSSMClient(client ssmiface.SSMAPI) SSMIface {
 	}
 }
 
-// GetSSMParameters ... get values from ssm parameter store
+// GetSSMParameters ... get values from ssm parameter store, split into requests within the API limit
+//
+// *InvalidParametersError is returned if any of the keys is not found.
 func (d *SSMInstance) GetSSMParameters(keys []string) (map[string]string, error) {
-	names := []*string{}
-	for _, k := range keys {
-		names = append(names, aws.String(k))
+	s := make(map[string]string)
+	invalids := []string{}
+	for i := 0; i < len(keys); i += maxParametersPerRequest {
+		end := i + maxParametersPerRequest
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		ssmParameters := &ssm.GetParametersInput{
+			Names:          aws.StringSlice(keys[i:end]),
+			WithDecryption: aws.Bool(true),
+		}
+
+		r, err := d.client.GetParameters(ssmParameters)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, p := range r.Parameters {
+			s[*p.Name] = *p.Value
+		}
+		invalids = append(invalids, aws.StringValueSlice(r.InvalidParameters)...)
 	}
 
-	ssmParameters := &ssm.GetParametersInput{
-		Names:          names,
-		WithDecryption: aws.Bool(true),
+	if len(invalids) > 0 {
+		return nil, &InvalidParametersError{Names: invalids}
 	}
+	return s, nil
+}
 
-	r, err := d.client.GetParameters(ssmParameters)
-	if err != nil {
-		return nil, err
+// GetSSMParametersByPath ... get values of every parameter under the path from ssm parameter store
+func (d *SSMInstance) GetSSMParametersByPath(path string) (map[string]string, error) {
+	input := &ssm.GetParametersByPathInput{
+		Path:           aws.String(path),
+		Recursive:      aws.Bool(true),
+		WithDecryption: aws.Bool(true),
 	}
 
 	s := make(map[string]string)
-	for _, p := range r.Parameters {
-		s[*p.Name] = *p.Value
+	err := d.client.GetParametersByPathPages(input, func(r *ssm.GetParametersByPathOutput, lastPage bool) bool {
+		for _, p := range r.Parameters {
+			s[*p.Name] = *p.Value
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
 	}
 	return s, nil
 }
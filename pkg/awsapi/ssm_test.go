@@ -2,6 +2,7 @@ package awsapi
 
 import (
 	"errors"
+	"fmt"
 	"testing"
 	"time"
 
@@ -16,10 +17,45 @@ type mockSSMClient struct {
 
 	Output *ssm.GetParametersOutput
 	Error  error
+
+	// Parameters : values of the parameters, used instead of Output if set
+	Parameters map[string]string
+	// pathOutputs : pages of GetParametersByPath
+	pathOutputs []*ssm.GetParametersByPathOutput
+	// requests : names of each GetParameters request
+	requests  [][]string
+	pathInput *ssm.GetParametersByPathInput
+}
+
+func (m *mockSSMClient) GetParameters(input *ssm.GetParametersInput) (*ssm.GetParametersOutput, error) {
+	m.requests = append(m.requests, aws.StringValueSlice(input.Names))
+	if m.Parameters == nil {
+		return m.Output, m.Error
+	}
+
+	o := &ssm.GetParametersOutput{}
+	for _, name := range input.Names {
+		v, ok := m.Parameters[*name]
+		if !ok {
+			o.InvalidParameters = append(o.InvalidParameters, name)
+			continue
+		}
+		o.Parameters = append(o.Parameters, &ssm.Parameter{Name: name, Value: aws.String(v)})
+	}
+	return o, m.Error
 }
 
-func (m *mockSSMClient) GetParameters(*ssm.GetParametersInput) (*ssm.GetParametersOutput, error) {
-	return m.Output, m.Error
+func (m *mockSSMClient) GetParametersByPathPages(input *ssm.GetParametersByPathInput, fn func(*ssm.GetParametersByPathOutput, bool) bool) error {
+	m.pathInput = input
+	if m.Error != nil {
+		return m.Error
+	}
+	for i, o := range m.pathOutputs {
+		if !fn(o, i == len(m.pathOutputs)-1) {
+			break
+		}
+	}
+	return nil
 }
 
 func TestGetSSMParameters(t *testing.T) {
@@ -86,3 +122,89 @@ func TestGetSSMParametersFailed(t *testing.T) {
 		t.Errorf("wrong result : err is nil")
 	}
 }
+
+func TestGetSSMParametersInChunks(t *testing.T) {
+	parameters := map[string]string{}
+	keys := []string{}
+	for i := 0; i < 12; i++ {
+		k := fmt.Sprintf("key%02d", i)
+		parameters[k] = fmt.Sprintf("value%02d", i)
+		keys = append(keys, k)
+	}
+	client := &mockSSMClient{Parameters: parameters}
+
+	s, err := NewSSMClient(client).GetSSMParameters(keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(parameters, s); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	// 1 リクエストあたり 10 件までに分割する
+	if diff := cmp.Diff([][]string{keys[:10], keys[10:]}, client.requests); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestGetSSMParametersInvalid(t *testing.T) {
+	keys := []string{}
+	for i := 0; i < 12; i++ {
+		keys = append(keys, fmt.Sprintf("key%02d", i))
+	}
+	client := &mockSSMClient{
+		Parameters: map[string]string{"key00": "value00"},
+	}
+
+	_, err := NewSSMClient(client).GetSSMParameters(keys)
+	e, ok := err.(*InvalidParametersError)
+	if !ok {
+		t.Fatalf("wrong result : %v", err)
+	}
+	// 全てのチャンクの存在しない名前をまとめて返す
+	if diff := cmp.Diff(keys[1:], e.Names); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if diff := cmp.Diff("invalid SSM parameters: key01, key02, key03, key04, key05, key06, key07, key08, key09, key10, key11", e.Error()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestGetSSMParametersByPath(t *testing.T) {
+	client := &mockSSMClient{
+		pathOutputs: []*ssm.GetParametersByPathOutput{
+			{
+				Parameters: []*ssm.Parameter{
+					{Name: aws.String("/datadog/api_key"), Value: aws.String("hogehoge")},
+				},
+				NextToken: aws.String("1"),
+			},
+			{
+				Parameters: []*ssm.Parameter{
+					{Name: aws.String("/datadog/app_key"), Value: aws.String("mogemoge")},
+				},
+			},
+		},
+	}
+
+	s, err := NewSSMClient(client).GetSSMParametersByPath("/datadog")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		"/datadog/api_key": "hogehoge",
+		"/datadog/app_key": "mogemoge",
+	}
+	if diff := cmp.Diff(expected, s); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if !aws.BoolValue(client.pathInput.Recursive) || !aws.BoolValue(client.pathInput.WithDecryption) {
+		t.Errorf("wrong result : %s", client.pathInput)
+	}
+}
+
+func TestGetSSMParametersByPathFailed(t *testing.T) {
+	client := &mockSSMClient{Error: errors.New("error occured")}
+	if _, err := NewSSMClient(client).GetSSMParametersByPath("/datadog"); err == nil {
+		t.Errorf("wrong result : err is nil")
+	}
+}
@@ -0,0 +1,33 @@
+package awsapi
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+)
+
+// STSIface : sts interface
+type STSIface interface {
+	GetAccountID() (string, error)
+}
+
+// STSInstance : sts instance
+type STSInstance struct {
+	client stsiface.STSAPI
+}
+
+// NewSTS ... generate new sts client
+func NewSTS(client stsiface.STSAPI) STSIface {
+	return &STSInstance{
+		client: client,
+	}
+}
+
+// GetAccountID ... ID of the account of the credentials
+func (s *STSInstance) GetAccountID() (string, error) {
+	r, err := s.client.GetCallerIdentity(&sts.GetCallerIdentityInput{})
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(r.Account), nil
+}
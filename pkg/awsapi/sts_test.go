@@ -0,0 +1,50 @@
+package awsapi
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sts"
+	"github.com/aws/aws-sdk-go/service/sts/stsiface"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockSTSClient struct {
+	stsiface.STSAPI
+
+	Output *sts.GetCallerIdentityOutput
+	Error  error
+}
+
+func (m *mockSTSClient) GetCallerIdentity(*sts.GetCallerIdentityInput) (*sts.GetCallerIdentityOutput, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	return m.Output, nil
+}
+
+func TestGetAccountID(t *testing.T) {
+	s := NewSTS(&mockSTSClient{
+		Output: &sts.GetCallerIdentityOutput{
+			Account: aws.String("111111111111"),
+			Arn:     aws.String("arn:aws:sts::111111111111:assumed-role/ri-utilization-plotter/ri-utilization-plotter"),
+		},
+	})
+
+	id, err := s.GetAccountID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("111111111111", id); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestGetAccountIDError(t *testing.T) {
+	s := NewSTS(&mockSTSClient{Error: errors.New("ExpiredToken")})
+
+	if _, err := s.GetAccountID(); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
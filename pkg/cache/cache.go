@@ -0,0 +1,223 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// dateLayout : layout of the days of Cost Explorer periods
+const dateLayout = "2006-01-02"
+
+// Store : storage of cached responses
+type Store interface {
+	// Name ... name of the storage used in error reports
+	Name() string
+	// Get ... value of the key, false if it is not stored
+	Get(key string) ([]byte, bool, error)
+	// Put ... store the value of the key, overwriting the existing one
+	Put(key string, value []byte) error
+}
+
+// Cache : responses of Cost Explorer for finalized periods, shared by the clients of a run
+//
+// The data of a day is finalized finalizedDays days later, so that a period ending by then is never fetched again.
+type Cache struct {
+	store         Store
+	finalizedDays int
+	// now : clock deciding whether a period is finalized
+	now func() time.Time
+
+	mu sync.Mutex
+	// saved : requests answered by the cache during the run
+	saved int
+	// failures : operations of the storage failed during the run
+	failures int
+	// firstErr : first failure of the storage
+	firstErr error
+}
+
+// New ... generate a cache keeping the responses for the periods finalized finalizedDays days before now in the store
+//
+// now is the clock of the caller deciding the periods, so that both agree on the current day.
+func New(store Store, finalizedDays int, now func() time.Time) *Cache {
+	return &Cache{
+		store:         store,
+		finalizedDays: finalizedDays,
+		now:           now,
+	}
+}
+
+// Wrap ... the client whose responses are cached, as is for a nil cache
+//
+// The scope, e.g. the account, the linked account and the group-by dimensions, is a part of the keys,
+// so that clients returning different data never share responses.
+func (c *Cache) Wrap(client awsapi.CostexplorerIface, scope ...string) awsapi.CostexplorerIface {
+	if c == nil {
+		return client
+	}
+	return &Costexplorer{
+		client: client,
+		cache:  c,
+		scope:  scope,
+	}
+}
+
+// Saved ... number of the requests answered by the cache, which are not sent to Cost Explorer
+func (c *Cache) Saved() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.saved
+}
+
+// Err ... failures of the storage, nil if there are none
+//
+// Requests are sent to Cost Explorer when the storage fails, so that the failures do not lose any data.
+func (c *Cache) Err() error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures == 0 {
+		return nil
+	}
+	return errors.Wrap(c.firstErr, fmt.Sprintf("%d operations of %s failed, the first one", c.failures, c.store.Name()))
+}
+
+// finalized ... whether the data of the period ending at end (exclusive) no longer changes
+func (c *Cache) finalized(end string) bool {
+	e, err := time.Parse(dateLayout, end)
+	if err != nil {
+		return false
+	}
+	n := c.now().UTC()
+	today := time.Date(n.Year(), n.Month(), n.Day(), 0, 0, 0, 0, time.UTC)
+	return !e.After(today.AddDate(0, 0, -c.finalizedDays))
+}
+
+// fetch ... the response of the request stored in result, fetched by fn unless it is cached
+//
+// Only responses for finalized periods are cached, and the others are always fetched.
+func (c *Cache) fetch(key, end string, result interface{}, fn func() error) error {
+	if !c.finalized(end) {
+		return fn()
+	}
+
+	v, ok, err := c.store.Get(key)
+	if err != nil {
+		c.fail(errors.Wrap(err, fmt.Sprintf("on Get of %s", key)))
+	} else if ok {
+		if err := json.Unmarshal(v, result); err == nil {
+			c.hit()
+			return nil
+		}
+		// a broken value is overwritten by the response fetched again
+	}
+
+	if err := fn(); err != nil {
+		return err
+	}
+	if v, err = json.Marshal(result); err != nil {
+		c.fail(errors.Wrap(err, fmt.Sprintf("on json.Marshal of %s", key)))
+		return nil
+	}
+	if err := c.store.Put(key, v); err != nil {
+		c.fail(errors.Wrap(err, fmt.Sprintf("on Put of %s", key)))
+	}
+	return nil
+}
+
+// fetchPeriods ... the results of each of the periods of a request, the finalized periods answered by the cache one by one
+//
+// Each finalized period is cached under its own key, so that a request whose last periods are not finalized,
+// e.g. the default period ending today, is answered by the cache except for them.
+// fetch(from) requests the periods from the first one which is not cached at once and sets their results,
+// and result(i) is the results of the i-th period, decoded from and encoded to the store.
+func (c *Cache) fetchPeriods(periods []awsapi.Period, key func(p awsapi.Period) string, result func(i int) interface{}, fetch func(from int) error) error {
+	from := 0
+	for ; from < len(periods); from++ {
+		p := periods[from]
+		if !c.finalized(p.End) {
+			break
+		}
+		v, ok, err := c.store.Get(key(p))
+		if err != nil {
+			c.fail(errors.Wrap(err, fmt.Sprintf("on Get of %s", key(p))))
+			break
+		}
+		// a broken value is overwritten by the response fetched again
+		if !ok || json.Unmarshal(v, result(from)) != nil {
+			break
+		}
+	}
+	if from == len(periods) {
+		c.hit()
+		return nil
+	}
+
+	if err := fetch(from); err != nil {
+		return err
+	}
+	for i := from; i < len(periods) && c.finalized(periods[i].End); i++ {
+		k := key(periods[i])
+		v, err := json.Marshal(result(i))
+		if err != nil {
+			c.fail(errors.Wrap(err, fmt.Sprintf("on json.Marshal of %s", k)))
+			continue
+		}
+		if err := c.store.Put(k, v); err != nil {
+			c.fail(errors.Wrap(err, fmt.Sprintf("on Put of %s", k)))
+		}
+	}
+	return nil
+}
+
+// periodIndex ... index of the period from the from-th one containing the time period starting at start
+func periodIndex(periods []awsapi.Period, from int, start time.Time) int {
+	day := start.UTC().Format(dateLayout)
+	i := from
+	for i+1 < len(periods) && periods[i+1].Start <= day {
+		i++
+	}
+	return i
+}
+
+func (c *Cache) hit() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.saved++
+}
+
+func (c *Cache) fail(err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failures == 0 {
+		c.firstErr = err
+	}
+	c.failures++
+}
+
+// key ... key of the request, the parts escaped and joined by "/", "-" for an empty part
+//
+// e.g. FetchRICoverage/111111111111/-/REGION,INSTANCE_TYPE/Amazon%20Redshift/2020-03-01/2020-03-03/DAILY
+func key(api string, parts ...string) string {
+	escaped := []string{api}
+	for _, p := range parts {
+		if p == "" {
+			p = "-"
+		}
+		escaped = append(escaped, url.PathEscape(p))
+	}
+	return strings.Join(escaped, "/")
+}
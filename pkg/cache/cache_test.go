@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// mockStore : store of the values in memory
+type mockStore struct {
+	values map[string][]byte
+	Error  error
+	gets   int
+	puts   int
+}
+
+func newMockStore() *mockStore {
+	return &mockStore{values: map[string][]byte{}}
+}
+
+func (m *mockStore) Name() string {
+	return "mock"
+}
+
+func (m *mockStore) Get(key string) ([]byte, bool, error) {
+	m.gets++
+	if m.Error != nil {
+		return nil, false, m.Error
+	}
+	v, ok := m.values[key]
+	return v, ok, nil
+}
+
+func (m *mockStore) Put(key string, value []byte) error {
+	m.puts++
+	if m.Error != nil {
+		return m.Error
+	}
+	m.values[key] = value
+	return nil
+}
+
+// newTestCache ... cache whose clock is 2020-03-10 12:00 UTC
+func newTestCache(store Store, finalizedDays int) *Cache {
+	return New(store, finalizedDays, func() time.Time {
+		return time.Date(2020, 3, 10, 12, 0, 0, 0, time.UTC)
+	})
+}
+
+func TestFinalized(t *testing.T) {
+	c := newTestCache(newMockStore(), 3)
+	tests := []struct {
+		end      string
+		expected bool
+	}{
+		{end: "2020-03-01", expected: true},
+		// 2020-03-06 のデータは 3 日後の 03-09 に確定する
+		{end: "2020-03-07", expected: true},
+		{end: "2020-03-08", expected: false},
+		{end: "2020-03-10", expected: false},
+		{end: "invalid", expected: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.end, func(t *testing.T) {
+			if actual := c.finalized(tt.end); actual != tt.expected {
+				t.Errorf("wrong result : %v", actual)
+			}
+		})
+	}
+}
+
+func TestKey(t *testing.T) {
+	actual := key("FetchRICoverage", "111111111111", "", "REGION,INSTANCE_TYPE", "Amazon Redshift", "2020-03-01", "2020-03-03", "DAILY")
+	expected := "FetchRICoverage/111111111111/-/REGION%2CINSTANCE_TYPE/Amazon%20Redshift/2020-03-01/2020-03-03/DAILY"
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+
+	// スラッシュを含むサービス名でも階層がずれない
+	if actual := key("FetchRIUtilization", "a/b"); actual != "FetchRIUtilization/a%2Fb" {
+		t.Errorf("wrong result : %s", actual)
+	}
+}
+
+// ストレージの失敗は件数と最初のエラーで報告する
+func TestErr(t *testing.T) {
+	c := newTestCache(newMockStore(), 3)
+	if err := c.Err(); err != nil {
+		t.Errorf("wrong result : %v", err)
+	}
+
+	c.fail(errors.New("AccessDenied"))
+	c.fail(errors.New("Throttling"))
+	err := c.Err()
+	if err == nil {
+		t.Fatal("wrong result : err is nil")
+	}
+	if diff := cmp.Diff("2 operations of mock failed, the first one: AccessDenied", err.Error()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// nil の cache はキャッシュせずに client をそのまま返す
+func TestNilCache(t *testing.T) {
+	var c *Cache
+	client := &mockCostexplorer{}
+	if c.Wrap(client, "111111111111") != client {
+		t.Error("wrong result : the client is wrapped")
+	}
+	if c.Saved() != 0 {
+		t.Errorf("wrong result : %d", c.Saved())
+	}
+	if err := c.Err(); err != nil {
+		t.Errorf("wrong result : %v", err)
+	}
+}
@@ -0,0 +1,242 @@
+package cache
+
+import (
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// Costexplorer : Cost Explorer client answering the requests for finalized periods from the cache
+type Costexplorer struct {
+	client awsapi.CostexplorerIface
+	cache  *Cache
+	// scope : parts of the keys distinguishing the client, e.g. the account
+	scope []string
+}
+
+// key ... key of the request of the API with the arguments
+func (c *Costexplorer) key(api string, args ...string) string {
+	return key(api, append(append([]string{}, c.scope...), args...)...)
+}
+
+// splitPeriods ... time periods of the request, false unless it is split into any, e.g. for an invalid date left for Cost Explorer to reject
+func splitPeriods(startDay, endDay, granularity string) ([]awsapi.Period, bool) {
+	periods, err := awsapi.Periods(startDay, endDay, granularity)
+	if err != nil || len(periods) == 0 {
+		return nil, false
+	}
+	return periods, true
+}
+
+// FetchReservedServices ... services with reservations, cached once the period is finalized
+func (c *Costexplorer) FetchReservedServices(startDay, endDay string) ([]string, error) {
+	var r []string
+	err := c.cache.fetch(c.key("FetchReservedServices", startDay, endDay), endDay, &r, func() (err error) {
+		r, err = c.client.FetchReservedServices(startDay, endDay)
+		return err
+	})
+	return r, err
+}
+
+// FetchRIUtilization ... RI utilization, each finalized period cached
+func (c *Costexplorer) FetchRIUtilization(service, startDay, endDay, granularity string) ([]*awsapi.RIUtilization, error) {
+	periods, ok := splitPeriods(startDay, endDay, granularity)
+	if !ok {
+		return c.client.FetchRIUtilization(service, startDay, endDay, granularity)
+	}
+	byPeriod := make([][]*awsapi.RIUtilization, len(periods))
+	err := c.cache.fetchPeriods(periods, func(p awsapi.Period) string {
+		return c.key("FetchRIUtilization", service, p.Start, p.End, granularity)
+	}, func(i int) interface{} {
+		return &byPeriod[i]
+	}, func(from int) error {
+		r, err := c.client.FetchRIUtilization(service, periods[from].Start, endDay, granularity)
+		if err != nil {
+			return err
+		}
+		for i := from; i < len(periods); i++ {
+			byPeriod[i] = []*awsapi.RIUtilization{}
+		}
+		for _, v := range r {
+			i := periodIndex(periods, from, v.Start)
+			byPeriod[i] = append(byPeriod[i], v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := []*awsapi.RIUtilization{}
+	for _, p := range byPeriod {
+		r = append(r, p...)
+	}
+	return r, nil
+}
+
+// FetchRIUtilizationBySubscription ... RI utilization of each reservation, cached once the period is finalized
+func (c *Costexplorer) FetchRIUtilizationBySubscription(service, startDay, endDay string) ([]*awsapi.RISubscriptionUtilization, error) {
+	var r []*awsapi.RISubscriptionUtilization
+	err := c.cache.fetch(c.key("FetchRIUtilizationBySubscription", service, startDay, endDay), endDay, &r, func() (err error) {
+		r, err = c.client.FetchRIUtilizationBySubscription(service, startDay, endDay)
+		return err
+	})
+	return r, err
+}
+
+// FetchRIUtilizationByLinkedAccount ... RI utilization of each linked account, cached once the period is finalized
+func (c *Costexplorer) FetchRIUtilizationByLinkedAccount(service, startDay, endDay string) ([]*awsapi.RILinkedAccountUtilization, error) {
+	var r []*awsapi.RILinkedAccountUtilization
+	err := c.cache.fetch(c.key("FetchRIUtilizationByLinkedAccount", service, startDay, endDay), endDay, &r, func() (err error) {
+		r, err = c.client.FetchRIUtilizationByLinkedAccount(service, startDay, endDay)
+		return err
+	})
+	return r, err
+}
+
+// FetchRICoverage ... RI coverage, each finalized period cached
+func (c *Costexplorer) FetchRICoverage(service, startDay, endDay, granularity string) ([]*awsapi.RICoverage, error) {
+	periods, ok := splitPeriods(startDay, endDay, granularity)
+	if !ok {
+		return c.client.FetchRICoverage(service, startDay, endDay, granularity)
+	}
+	byPeriod := make([][]*awsapi.RICoverage, len(periods))
+	err := c.cache.fetchPeriods(periods, func(p awsapi.Period) string {
+		return c.key("FetchRICoverage", service, p.Start, p.End, granularity)
+	}, func(i int) interface{} {
+		return &byPeriod[i]
+	}, func(from int) error {
+		r, err := c.client.FetchRICoverage(service, periods[from].Start, endDay, granularity)
+		if err != nil {
+			return err
+		}
+		for i := from; i < len(periods); i++ {
+			byPeriod[i] = []*awsapi.RICoverage{}
+		}
+		for _, v := range r {
+			i := periodIndex(periods, from, v.Start)
+			byPeriod[i] = append(byPeriod[i], v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := []*awsapi.RICoverage{}
+	for _, p := range byPeriod {
+		r = append(r, p...)
+	}
+	return r, nil
+}
+
+// FetchRICoverageByLinkedAccount ... RI coverage of each linked account, each finalized period cached
+func (c *Costexplorer) FetchRICoverageByLinkedAccount(service, startDay, endDay, granularity string) ([]*awsapi.RICoverage, error) {
+	periods, ok := splitPeriods(startDay, endDay, granularity)
+	if !ok {
+		return c.client.FetchRICoverageByLinkedAccount(service, startDay, endDay, granularity)
+	}
+	byPeriod := make([][]*awsapi.RICoverage, len(periods))
+	err := c.cache.fetchPeriods(periods, func(p awsapi.Period) string {
+		return c.key("FetchRICoverageByLinkedAccount", service, p.Start, p.End, granularity)
+	}, func(i int) interface{} {
+		return &byPeriod[i]
+	}, func(from int) error {
+		r, err := c.client.FetchRICoverageByLinkedAccount(service, periods[from].Start, endDay, granularity)
+		if err != nil {
+			return err
+		}
+		for i := from; i < len(periods); i++ {
+			byPeriod[i] = []*awsapi.RICoverage{}
+		}
+		for _, v := range r {
+			i := periodIndex(periods, from, v.Start)
+			byPeriod[i] = append(byPeriod[i], v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := []*awsapi.RICoverage{}
+	for _, p := range byPeriod {
+		r = append(r, p...)
+	}
+	return r, nil
+}
+
+// FetchSavingsPlansUtilization ... Savings Plans utilization, each finalized period cached
+func (c *Costexplorer) FetchSavingsPlansUtilization(startDay, endDay, granularity string) ([]*awsapi.SavingsPlansUtilization, error) {
+	periods, ok := splitPeriods(startDay, endDay, granularity)
+	if !ok {
+		return c.client.FetchSavingsPlansUtilization(startDay, endDay, granularity)
+	}
+	byPeriod := make([][]*awsapi.SavingsPlansUtilization, len(periods))
+	err := c.cache.fetchPeriods(periods, func(p awsapi.Period) string {
+		return c.key("FetchSavingsPlansUtilization", p.Start, p.End, granularity)
+	}, func(i int) interface{} {
+		return &byPeriod[i]
+	}, func(from int) error {
+		r, err := c.client.FetchSavingsPlansUtilization(periods[from].Start, endDay, granularity)
+		if err != nil {
+			return err
+		}
+		for i := from; i < len(periods); i++ {
+			byPeriod[i] = []*awsapi.SavingsPlansUtilization{}
+		}
+		for _, v := range r {
+			i := periodIndex(periods, from, v.Start)
+			byPeriod[i] = append(byPeriod[i], v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := []*awsapi.SavingsPlansUtilization{}
+	for _, p := range byPeriod {
+		r = append(r, p...)
+	}
+	return r, nil
+}
+
+// FetchSavingsPlansUtilizationDetails ... utilization of each Savings Plan, cached once the period is finalized
+func (c *Costexplorer) FetchSavingsPlansUtilizationDetails(startDay, endDay string) ([]*awsapi.SavingsPlansUtilization, error) {
+	var r []*awsapi.SavingsPlansUtilization
+	err := c.cache.fetch(c.key("FetchSavingsPlansUtilizationDetails", startDay, endDay), endDay, &r, func() (err error) {
+		r, err = c.client.FetchSavingsPlansUtilizationDetails(startDay, endDay)
+		return err
+	})
+	return r, err
+}
+
+// FetchSavingsPlansCoverage ... Savings Plans coverage, each finalized period cached
+func (c *Costexplorer) FetchSavingsPlansCoverage(startDay, endDay, granularity string) ([]*awsapi.SavingsPlansCoverage, error) {
+	periods, ok := splitPeriods(startDay, endDay, granularity)
+	if !ok {
+		return c.client.FetchSavingsPlansCoverage(startDay, endDay, granularity)
+	}
+	byPeriod := make([][]*awsapi.SavingsPlansCoverage, len(periods))
+	err := c.cache.fetchPeriods(periods, func(p awsapi.Period) string {
+		return c.key("FetchSavingsPlansCoverage", p.Start, p.End, granularity)
+	}, func(i int) interface{} {
+		return &byPeriod[i]
+	}, func(from int) error {
+		r, err := c.client.FetchSavingsPlansCoverage(periods[from].Start, endDay, granularity)
+		if err != nil {
+			return err
+		}
+		for i := from; i < len(periods); i++ {
+			byPeriod[i] = []*awsapi.SavingsPlansCoverage{}
+		}
+		for _, v := range r {
+			i := periodIndex(periods, from, v.Start)
+			byPeriod[i] = append(byPeriod[i], v)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	r := []*awsapi.SavingsPlansCoverage{}
+	for _, p := range byPeriod {
+		r = append(r, p...)
+	}
+	return r, nil
+}
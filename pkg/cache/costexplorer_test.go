@@ -0,0 +1,221 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// mockCostexplorer : returns the utilization of each day of the request, counting the requests
+type mockCostexplorer struct {
+	awsapi.CostexplorerIface
+
+	Error error
+	calls int
+	// requests : "start end" of the requests of FetchRIUtilization
+	requests []string
+}
+
+func (m *mockCostexplorer) FetchRIUtilization(service, startDay, endDay, granularity string) ([]*awsapi.RIUtilization, error) {
+	m.calls++
+	m.requests = append(m.requests, startDay+" "+endDay)
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	periods, err := awsapi.Periods(startDay, endDay, granularity)
+	if err != nil {
+		return nil, err
+	}
+	utils := []*awsapi.RIUtilization{}
+	for _, p := range periods {
+		start, _ := time.Parse(dateLayout, p.Start)
+		utils = append(utils, &awsapi.RIUtilization{Start: start, UtilizationPercentage: 80})
+	}
+	return utils, nil
+}
+
+func (m *mockCostexplorer) FetchSavingsPlansUtilization(startDay, endDay, granularity string) ([]*awsapi.SavingsPlansUtilization, error) {
+	m.calls++
+	return []*awsapi.SavingsPlansUtilization{}, m.Error
+}
+
+// dailyUtilizations ... utilization of each day from start to end (exclusive)
+func dailyUtilizations(start, end string) []*awsapi.RIUtilization {
+	utils := []*awsapi.RIUtilization{}
+	s, _ := time.Parse(dateLayout, start)
+	e, _ := time.Parse(dateLayout, end)
+	for d := s; d.Before(e); d = d.AddDate(0, 0, 1) {
+		utils = append(utils, &awsapi.RIUtilization{Start: d, UtilizationPercentage: 80})
+	}
+	return utils
+}
+
+func TestFetchRIUtilizationCached(t *testing.T) {
+	tests := []struct {
+		name  string
+		start string
+		end   string
+		// requests : periods requested to Cost Explorer by 2 fetches
+		requests []string
+		saved    int
+	}{
+		{
+			// 確定した期間は 2 回目からキャッシュを返す
+			name:     "finalized period",
+			start:    "2020-03-01",
+			end:      "2020-03-03",
+			requests: []string{"2020-03-01 2020-03-03"},
+			saved:    1,
+		},
+		{
+			// 確定していない日だけを取得し直す
+			name:     "recent period",
+			start:    "2020-03-01",
+			end:      "2020-03-10",
+			requests: []string{"2020-03-01 2020-03-10", "2020-03-07 2020-03-10"},
+			saved:    0,
+		},
+		{
+			// 確定した日が無ければ毎回全体を取得する
+			name:     "not finalized at all",
+			start:    "2020-03-08",
+			end:      "2020-03-10",
+			requests: []string{"2020-03-08 2020-03-10", "2020-03-08 2020-03-10"},
+			saved:    0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockCostexplorer{}
+			c := newTestCache(newMockStore(), 3)
+			cached := c.Wrap(client, "111111111111")
+
+			for i := 0; i < 2; i++ {
+				actual, err := cached.FetchRIUtilization("Amazon Redshift", tt.start, tt.end, "DAILY")
+				if err != nil {
+					t.Fatal(err)
+				}
+				if diff := cmp.Diff(dailyUtilizations(tt.start, tt.end), actual); diff != "" {
+					t.Errorf("wrong result : %s", diff)
+				}
+			}
+			if diff := cmp.Diff(tt.requests, client.requests); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+			if c.Saved() != tt.saved {
+				t.Errorf("wrong result : %d saved", c.Saved())
+			}
+		})
+	}
+}
+
+// 期間の一部が確定した別のリクエストも日ごとにキャッシュを共有する
+func TestFetchRIUtilizationCachedPerPeriod(t *testing.T) {
+	client := &mockCostexplorer{}
+	c := newTestCache(newMockStore(), 3)
+	cached := c.Wrap(client, "111111111111")
+
+	for _, start := range []string{"2020-03-01", "2020-03-05"} {
+		actual, err := cached.FetchRIUtilization("Amazon Redshift", start, "2020-03-10", "DAILY")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(dailyUtilizations(start, "2020-03-10"), actual); diff != "" {
+			t.Errorf("wrong result : %s", diff)
+		}
+	}
+	if diff := cmp.Diff([]string{"2020-03-01 2020-03-10", "2020-03-07 2020-03-10"}, client.requests); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// scope の異なる client はキャッシュを共有しない
+func TestCacheScope(t *testing.T) {
+	c := newTestCache(newMockStore(), 3)
+	a, b := &mockCostexplorer{}, &mockCostexplorer{}
+
+	for _, client := range []awsapi.CostexplorerIface{c.Wrap(a, "111111111111"), c.Wrap(b, "222222222222")} {
+		if _, err := client.FetchRIUtilization("Amazon Redshift", "2020-03-01", "2020-03-03", "DAILY"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a.calls != 1 || b.calls != 1 || c.Saved() != 0 {
+		t.Errorf("wrong result : %d, %d calls and %d saved", a.calls, b.calls, c.Saved())
+	}
+}
+
+// 取得に失敗したレスポンスはキャッシュしない
+func TestFetchFailedNotCached(t *testing.T) {
+	store := newMockStore()
+	c := newTestCache(store, 3)
+	client := &mockCostexplorer{Error: errors.New("AccessDeniedException")}
+
+	if _, err := c.Wrap(client).FetchRIUtilization("Amazon Redshift", "2020-03-01", "2020-03-03", "DAILY"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+	if len(store.values) != 0 {
+		t.Errorf("wrong result : %d values are cached", len(store.values))
+	}
+}
+
+// ストレージが失敗しても Cost Explorer から取得する
+func TestStoreFailed(t *testing.T) {
+	store := newMockStore()
+	store.Error = errors.New("AccessDenied")
+	c := newTestCache(store, 3)
+	client := &mockCostexplorer{}
+
+	actual, err := c.Wrap(client).FetchRIUtilization("Amazon Redshift", "2020-03-01", "2020-03-03", "DAILY")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual) != 2 || client.calls != 1 {
+		t.Errorf("wrong result : %d utilizations by %d calls", len(actual), client.calls)
+	}
+	if c.Err() == nil {
+		t.Error("wrong result : the failures are not reported")
+	}
+}
+
+// 壊れた値は取得し直して上書きする
+func TestBrokenValueRefetched(t *testing.T) {
+	store := newMockStore()
+	c := newTestCache(store, 3)
+	k := key("FetchRIUtilization", "Amazon Redshift", "2020-03-01", "2020-03-02", "DAILY")
+	store.values[k] = []byte("{")
+	client := &mockCostexplorer{}
+
+	if _, err := c.Wrap(client).FetchRIUtilization("Amazon Redshift", "2020-03-01", "2020-03-03", "DAILY"); err != nil {
+		t.Fatal(err)
+	}
+	if client.calls != 1 || c.Saved() != 0 {
+		t.Errorf("wrong result : %d calls and %d saved", client.calls, c.Saved())
+	}
+	if string(store.values[k]) == "{" {
+		t.Error("wrong result : the broken value is not overwritten")
+	}
+}
+
+// Savings Plans を利用していない空のレスポンスもキャッシュする
+func TestFetchSavingsPlansUtilizationCached(t *testing.T) {
+	c := newTestCache(newMockStore(), 3)
+	client := &mockCostexplorer{}
+	cached := c.Wrap(client)
+
+	for i := 0; i < 2; i++ {
+		u, err := cached.FetchSavingsPlansUtilization("2020-03-01", "2020-03-03", "DAILY")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(u) != 0 {
+			t.Errorf("wrong result : %v", u)
+		}
+	}
+	if client.calls != 1 || c.Saved() != 1 {
+		t.Errorf("wrong result : %d calls and %d saved", client.calls, c.Saved())
+	}
+}
@@ -0,0 +1,34 @@
+package cache
+
+import (
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// DynamoDB : store values as items of a table whose partition key is the string "key"
+type DynamoDB struct {
+	client awsapi.DynamoDBIface
+	table  string
+}
+
+// NewDynamoDB ... generate a store of the items of the table
+func NewDynamoDB(client awsapi.DynamoDBIface, table string) *DynamoDB {
+	return &DynamoDB{
+		client: client,
+		table:  table,
+	}
+}
+
+// Name ... dynamodb
+func (d *DynamoDB) Name() string {
+	return "dynamodb"
+}
+
+// Get ... value of the item of the key, false if the item does not exist
+func (d *DynamoDB) Get(key string) ([]byte, bool, error) {
+	return d.client.GetValue(d.table, key)
+}
+
+// Put ... put the value as the item of the key
+func (d *DynamoDB) Put(key string, value []byte) error {
+	return d.client.PutValue(d.table, key, value)
+}
@@ -0,0 +1,38 @@
+package cache
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockDynamoDB struct {
+	// Items : values keyed by "table/key"
+	Items map[string][]byte
+}
+
+func (m *mockDynamoDB) GetValue(table, key string) ([]byte, bool, error) {
+	v, ok := m.Items[table+"/"+key]
+	return v, ok, nil
+}
+
+func (m *mockDynamoDB) PutValue(table, key string, value []byte) error {
+	m.Items[table+"/"+key] = value
+	return nil
+}
+
+func TestDynamoDB(t *testing.T) {
+	client := &mockDynamoDB{Items: map[string][]byte{}}
+	d := NewDynamoDB(client, "ri-utilization-plotter-cache")
+
+	if err := d.Put("FetchRIUtilization/a", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := d.Get("FetchRIUtilization/a")
+	if err != nil || !ok {
+		t.Fatalf("wrong result : %v, %v", ok, err)
+	}
+	if diff := cmp.Diff("[]", string(v)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
@@ -0,0 +1,64 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// File : store values as files under a local directory
+//
+// On Lambda, only /tmp is writable and the files are kept while the container is warm.
+type File struct {
+	dir string
+}
+
+// NewFile ... generate a store of the files under the directory
+func NewFile(dir string) *File {
+	return &File{
+		dir: dir,
+	}
+}
+
+// Name ... file
+func (f *File) Name() string {
+	return "file"
+}
+
+// Get ... content of the file of the key, false if the file does not exist
+func (f *File) Get(key string) ([]byte, bool, error) {
+	v, err := ioutil.ReadFile(f.path(key))
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Put ... write the value to the file of the key through a temporary file, so that a reader never sees a partial value
+func (f *File) Put(key string, value []byte) error {
+	path := f.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(value); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func (f *File) path(key string) string {
+	return filepath.Join(f.dir, filepath.FromSlash(key)+".json")
+}
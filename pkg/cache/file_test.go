@@ -0,0 +1,46 @@
+package cache
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cache")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	f := NewFile(dir)
+
+	if _, ok, err := f.Get("FetchRIUtilization/Amazon%20Redshift"); ok || err != nil {
+		t.Errorf("wrong result : %v, %v", ok, err)
+	}
+
+	if err := f.Put("FetchRIUtilization/Amazon%20Redshift", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	v, ok, err := f.Get("FetchRIUtilization/Amazon%20Redshift")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("wrong result : the value is not found")
+	}
+	if diff := cmp.Diff("[]", string(v)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+
+	// 一時ファイルは残らない
+	files, err := ioutil.ReadDir(filepath.Join(dir, "FetchRIUtilization"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Errorf("wrong result : %d files", len(files))
+	}
+}
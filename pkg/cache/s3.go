@@ -0,0 +1,52 @@
+package cache
+
+import (
+	"path"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// S3 : store values as objects under a prefix of a bucket
+type S3 struct {
+	client awsapi.S3Iface
+	bucket string
+	prefix string
+}
+
+// NewS3 ... generate a store of the objects under the prefix of the bucket
+func NewS3(client awsapi.S3Iface, bucket, prefix string) *S3 {
+	return &S3{
+		client: client,
+		bucket: bucket,
+		prefix: prefix,
+	}
+}
+
+// Name ... s3
+func (s *S3) Name() string {
+	return "s3"
+}
+
+// Get ... content of the object of the key, false if the object does not exist
+//
+// 403 is a missing object as well, since S3 answers it for a missing object without s3:ListBucket.
+// A denied read is still reported by Put of the response fetched instead.
+func (s *S3) Get(key string) ([]byte, bool, error) {
+	v, err := s.client.GetObject(s.bucket, s.objectKey(key))
+	if awsapi.IsNoSuchKey(err) || awsapi.IsAccessDenied(err) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return v, true, nil
+}
+
+// Put ... put the value as the object of the key
+func (s *S3) Put(key string, value []byte) error {
+	return s.client.PutObject(s.bucket, s.objectKey(key), value)
+}
+
+func (s *S3) objectKey(key string) string {
+	return path.Join(s.prefix, key+".json")
+}
@@ -0,0 +1,74 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockS3 struct {
+	Objects map[string]string
+	Error   error
+}
+
+func (m *mockS3) GetObject(bucket, key string) ([]byte, error) {
+	if m.Error != nil {
+		return nil, m.Error
+	}
+	v, ok := m.Objects[bucket+"/"+key]
+	if !ok {
+		return nil, awserr.New(s3.ErrCodeNoSuchKey, "The specified key does not exist.", nil)
+	}
+	return []byte(v), nil
+}
+
+func (m *mockS3) PutObject(bucket, key string, body []byte) error {
+	if m.Error != nil {
+		return m.Error
+	}
+	m.Objects[bucket+"/"+key] = string(body)
+	return nil
+}
+
+func TestS3(t *testing.T) {
+	client := &mockS3{Objects: map[string]string{}}
+	s := NewS3(client, "bucket", "ri-utilization-plotter/cache")
+
+	if _, ok, err := s.Get("FetchRIUtilization/a"); ok || err != nil {
+		t.Errorf("wrong result : %v, %v", ok, err)
+	}
+	if err := s.Put("FetchRIUtilization/a", []byte("[]")); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff(map[string]string{"bucket/ri-utilization-plotter/cache/FetchRIUtilization/a.json": "[]"}, client.Objects); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	v, ok, err := s.Get("FetchRIUtilization/a")
+	if err != nil || !ok {
+		t.Fatalf("wrong result : %v, %v", ok, err)
+	}
+	if diff := cmp.Diff("[]", string(v)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// s3:ListBucket がなければ存在しないオブジェクトは 403 になるので、キャッシュにないものとして扱う
+func TestS3AccessDenied(t *testing.T) {
+	s := NewS3(&mockS3{Error: awserr.NewRequestFailure(awserr.New("AccessDenied", "Access Denied", nil), 403, "")}, "bucket", "")
+
+	if _, ok, err := s.Get("FetchRIUtilization/a"); ok || err != nil {
+		t.Errorf("wrong result : %v, %v", ok, err)
+	}
+}
+
+// NoSuchKey と 403 以外のエラーは失敗として返す
+func TestS3Failed(t *testing.T) {
+	s := NewS3(&mockS3{Error: errors.New("RequestTimeout")}, "bucket", "")
+
+	if _, _, err := s.Get("FetchRIUtilization/a"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"context"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+
+	"github.com/kenzo0107/ri-utilization-plotter/pkg/awsapi"
+)
+
+// maxDimensions : CloudWatch accepts up to 10 dimensions per metric
+const maxDimensions = 10
+
+// identityDimensions : dimensions telling the accounts apart, which are kept even when a metric has too many dimensions
+var identityDimensions = map[string]bool{
+	"account_id":    true,
+	"account_alias": true,
+}
+
+// CloudWatch : put metrics to a custom CloudWatch namespace
+type CloudWatch struct {
+	client    awsapi.CloudWatchIface
+	namespace string
+}
+
+// NewCloudWatch ... generate a sink putting metrics to the namespace
+func NewCloudWatch(client awsapi.CloudWatchIface, namespace string) *CloudWatch {
+	return &CloudWatch{
+		client:    client,
+		namespace: namespace,
+	}
+}
+
+// Name ... cloudwatch
+func (c *CloudWatch) Name() string {
+	return "cloudwatch"
+}
+
+// Post ... put metrics with "key:value" tags as dimensions, bare tags are dropped
+//
+// The last tags beyond the limit of dimensions are dropped, except those identifying the account.
+func (c *CloudWatch) Post(ctx context.Context, metrics []Metric) error {
+	data := make([]*cloudwatch.MetricDatum, 0, len(metrics))
+	for _, m := range metrics {
+		dimensions := []*cloudwatch.Dimension{}
+		for _, tag := range m.Tags {
+			k, v := splitTag(tag)
+			if k == "" || v == "" {
+				continue
+			}
+			dimensions = append(dimensions, &cloudwatch.Dimension{
+				Name:  aws.String(k),
+				Value: aws.String(v),
+			})
+		}
+
+		data = append(data, &cloudwatch.MetricDatum{
+			MetricName: aws.String(m.Name),
+			Dimensions: limitDimensions(dimensions),
+			Timestamp:  aws.Time(m.Timestamp),
+			Unit:       aws.String(cloudwatchUnit(m.Unit)),
+			Value:      aws.Float64(m.Value),
+		})
+	}
+	return c.client.PutMetricData(ctx, c.namespace, data)
+}
+
+// limitDimensions ... drop the last dimensions beyond maxDimensions, keeping the identity dimensions in order
+func limitDimensions(dimensions []*cloudwatch.Dimension) []*cloudwatch.Dimension {
+	identities := 0
+	for _, d := range dimensions {
+		if identityDimensions[*d.Name] {
+			identities++
+		}
+	}
+
+	limited := make([]*cloudwatch.Dimension, 0, maxDimensions)
+	others := 0
+	for _, d := range dimensions {
+		if !identityDimensions[*d.Name] {
+			if others == maxDimensions-identities {
+				continue
+			}
+			others++
+		}
+		limited = append(limited, d)
+	}
+	return limited
+}
+
+func cloudwatchUnit(unit string) string {
+	switch unit {
+	case UnitPercent:
+		return cloudwatch.StandardUnitPercent
+	}
+	return cloudwatch.StandardUnitNone
+}
@@ -0,0 +1,103 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/cloudwatch"
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockCloudWatch struct {
+	namespace string
+	data      []*cloudwatch.MetricDatum
+	Error     error
+}
+
+func (m *mockCloudWatch) PutMetricData(ctx context.Context, namespace string, data []*cloudwatch.MetricDatum) error {
+	m.namespace = namespace
+	m.data = append(m.data, data...)
+	return m.Error
+}
+
+func TestCloudWatchPost(t *testing.T) {
+	mock := &mockCloudWatch{}
+	c := NewCloudWatch(mock, "RIUtilizationPlotter")
+
+	metrics := append(testMetrics(), Metric{
+		Name:      "aws.ri.coverage",
+		Value:     50,
+		Timestamp: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+		Tags:      []string{"instance_type:t3.nano", "region:ap-northeast-1", "account:hoge", "hoge", "service:Amazon EC2"},
+	})
+	if err := c.Post(context.Background(), metrics); err != nil {
+		t.Error(err)
+	}
+
+	if diff := cmp.Diff("RIUtilizationPlotter", mock.namespace); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	expected := []*cloudwatch.MetricDatum{
+		{
+			MetricName: aws.String("aws.ri.utilization"),
+			Dimensions: []*cloudwatch.Dimension{
+				{Name: aws.String("account"), Value: aws.String("hoge")},
+				{Name: aws.String("service"), Value: aws.String("Amazon Redshift")},
+			},
+			Timestamp: aws.Time(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)),
+			Unit:      aws.String("Percent"),
+			Value:     aws.Float64(80),
+		},
+		{
+			MetricName: aws.String("aws.ri.coverage"),
+			Dimensions: []*cloudwatch.Dimension{
+				{Name: aws.String("instance_type"), Value: aws.String("t3.nano")},
+				{Name: aws.String("region"), Value: aws.String("ap-northeast-1")},
+				{Name: aws.String("account"), Value: aws.String("hoge")},
+				{Name: aws.String("service"), Value: aws.String("Amazon EC2")},
+			},
+			Timestamp: aws.Time(time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)),
+			Unit:      aws.String("None"),
+			Value:     aws.Float64(50),
+		},
+	}
+	if diff := cmp.Diff(expected, mock.data); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// ディメンションの上限を超えたタグは後ろから落とし、アカウントを識別するタグは残す
+func TestCloudWatchPostTooManyDimensions(t *testing.T) {
+	mock := &mockCloudWatch{}
+	c := NewCloudWatch(mock, "RIUtilizationPlotter")
+
+	tags := []string{}
+	for i := 0; i < 10; i++ {
+		tags = append(tags, fmt.Sprintf("tag%d:value", i))
+	}
+	tags = append(tags, "account_id:111111111111", "account_alias:production")
+	if err := c.Post(context.Background(), []Metric{{Name: "aws.ri.utilization", Tags: tags}}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"tag0", "tag1", "tag2", "tag3", "tag4", "tag5", "tag6", "tag7", "account_id", "account_alias"}
+	actual := []string{}
+	for _, d := range mock.data[0].Dimensions {
+		actual = append(actual, *d.Name)
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestCloudWatchPostFailed(t *testing.T) {
+	c := NewCloudWatch(&mockCloudWatch{Error: errors.New("error occured")}, "RIUtilizationPlotter")
+
+	if err := c.Post(context.Background(), testMetrics()); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
@@ -0,0 +1,203 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/zorkian/go-datadog-api"
+)
+
+const (
+	typeGauge = "gauge"
+
+	// maxPayloadBytes : Datadog accepts series payloads up to 3.2 megabytes
+	maxPayloadBytes = 3200000
+	// payloadEnvelopeBytes : size of {"series":[]} wrapping the series
+	payloadEnvelopeBytes = len(`{"series":[]}`)
+	// maxRetryElapsedTime : time spent retrying a batch before giving up,
+	// shorter than the default DEADLINE_MARGIN left to post the metrics before the Lambda times out
+	maxRetryElapsedTime = 20 * time.Second
+)
+
+// apiErrorStatus : HTTP status code in errors of the Datadog client, e.g. "API error 429 Too Many Requests: ..."
+var apiErrorStatus = regexp.MustCompile(`^API error (\d{3})`)
+
+// Datadog : post metrics to Datadog
+type Datadog struct {
+	client *datadog.Client
+	host   string
+	// maxPayloadBytes : upper limit of the size of a request body
+	maxPayloadBytes int
+	// newBackOff : generate the backoff between retries of a batch
+	newBackOff func() backoff.BackOff
+}
+
+// NewDatadog ... generate a sink posting metrics to Datadog as gauges reported by the host
+func NewDatadog(client *datadog.Client, host string) *Datadog {
+	return &Datadog{
+		client:          client,
+		host:            host,
+		maxPayloadBytes: maxPayloadBytes,
+		newBackOff: func() backoff.BackOff {
+			b := backoff.NewExponentialBackOff()
+			b.MaxElapsedTime = maxRetryElapsedTime
+			return b
+		},
+	}
+}
+
+// Name ... datadog
+func (d *Datadog) Name() string {
+	return "datadog"
+}
+
+// BatchErrors : failures of the batches of a post, while the other batches are posted
+type BatchErrors struct {
+	Errs []error
+	// Batches : number of the batches of the post
+	Batches int
+}
+
+func (e *BatchErrors) Error() string {
+	msgs := make([]string, 0, len(e.Errs))
+	for _, err := range e.Errs {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d of %d batches failed: %s", len(e.Errs), e.Batches, strings.Join(msgs, "; "))
+}
+
+// Post ... post metrics as series in as few requests as the payload limit allows
+//
+// A batch rejected by rate limiting or a server error is retried with exponential backoff,
+// which is given up when ctx is done or its deadline comes before the next retry.
+// A batch failing even so does not stop the rest, and the failures are returned as BatchErrors
+// unless the metrics are posted in a single batch.
+func (d *Datadog) Post(ctx context.Context, metrics []Metric) error {
+	if len(metrics) == 0 {
+		return nil
+	}
+
+	batches, err := batchSeries(d.series(metrics), d.maxPayloadBytes)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, batch := range batches {
+		if err := d.postWithRetry(ctx, batch); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	switch {
+	case len(errs) == 0:
+		return nil
+	case len(batches) == 1:
+		return errs[0]
+	}
+	return &BatchErrors{Errs: errs, Batches: len(batches)}
+}
+
+// series ... metrics as gauges reported by the host
+func (d *Datadog) series(metrics []Metric) []datadog.Metric {
+	series := make([]datadog.Metric, 0, len(metrics))
+	for _, m := range metrics {
+		name := m.Name
+		typeDatadog := typeGauge
+		host := d.host
+		timestamp := float64(m.Timestamp.Unix())
+		value := m.Value
+
+		metric := datadog.Metric{
+			Metric: &name,
+			Points: []datadog.DataPoint{
+				{&timestamp, &value},
+			},
+			Type: &typeDatadog,
+			Host: &host,
+			Tags: m.Tags,
+		}
+		if m.Unit != UnitNone {
+			unit := m.Unit
+			metric.Unit = &unit
+		}
+		series = append(series, metric)
+	}
+	return series
+}
+
+// postWithRetry ... post a batch, retrying it while Datadog responds 429 or 5xx
+func (d *Datadog) postWithRetry(ctx context.Context, batch []datadog.Metric) error {
+	return backoff.Retry(func() error {
+		err := d.client.PostMetrics(batch)
+		if err != nil && !retryable(err) {
+			return backoff.Permanent(err)
+		}
+		return err
+	}, backoff.WithContext(d.newBackOff(), ctx))
+}
+
+// batchSeries ... split series into batches whose JSON payload is within maxBytes
+//
+// A series larger than maxBytes by itself is sent alone and left for Datadog to reject.
+func batchSeries(series []datadog.Metric, maxBytes int) ([][]datadog.Metric, error) {
+	batches := [][]datadog.Metric{}
+	batch := []datadog.Metric{}
+	size := payloadEnvelopeBytes
+	for _, s := range series {
+		b, err := json.Marshal(s)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) > 0 {
+			// series are separated by a comma
+			if size+1+len(b) > maxBytes {
+				batches = append(batches, batch)
+				batch, size = []datadog.Metric{}, payloadEnvelopeBytes
+			} else {
+				size++
+			}
+		}
+		batch = append(batch, s)
+		size += len(b)
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches, nil
+}
+
+// IsDatadogAuthError ... whether Datadog rejected the keys of the client, e.g. revoked or rotated keys
+func IsDatadogAuthError(err error) bool {
+	if b, ok := err.(*BatchErrors); ok {
+		for _, e := range b.Errs {
+			if IsDatadogAuthError(e) {
+				return true
+			}
+		}
+		return false
+	}
+	status := apiStatus(err)
+	return status == http.StatusUnauthorized || status == http.StatusForbidden
+}
+
+// retryable ... whether the error is rate limiting or a server error of Datadog
+func retryable(err error) bool {
+	status := apiStatus(err)
+	return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// apiStatus ... HTTP status code of the error of the Datadog client, 0 if it has none
+func apiStatus(err error) int {
+	m := apiErrorStatus.FindStringSubmatch(err.Error())
+	if m == nil {
+		return 0
+	}
+	status, _ := strconv.Atoi(m[1])
+	return status
+}
@@ -0,0 +1,267 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/backoff"
+	"github.com/google/go-cmp/cmp"
+	"github.com/zorkian/go-datadog-api"
+)
+
+// datadogServer : Datadog API responding with the statuses in order, the last one repeatedly
+type datadogServer struct {
+	*httptest.Server
+	// requests : series of each request
+	requests [][]datadog.Metric
+}
+
+func newDatadogClient(t *testing.T, statuses ...int) (*datadog.Client, *datadogServer) {
+	s := &datadogServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body := struct {
+			Series []datadog.Metric `json:"series"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Error(err)
+		}
+		status := statuses[len(statuses)-1]
+		if len(s.requests) < len(statuses) {
+			status = statuses[len(s.requests)]
+		}
+		s.requests = append(s.requests, body.Series)
+		w.WriteHeader(status)
+	}))
+
+	client := &datadog.Client{
+		HttpClient: http.DefaultClient,
+	}
+	client.SetBaseUrl(s.URL)
+	return client, s
+}
+
+// series ... series of every request
+func (s *datadogServer) series() []datadog.Metric {
+	series := []datadog.Metric{}
+	for _, r := range s.requests {
+		series = append(series, r...)
+	}
+	return series
+}
+
+// noBackOff ... retry up to the times without waiting
+func noBackOff(retries uint64) func() backoff.BackOff {
+	return func() backoff.BackOff {
+		return backoff.WithMaxRetries(&backoff.ZeroBackOff{}, retries)
+	}
+}
+
+func TestDatadogPost(t *testing.T) {
+	client, ts := newDatadogClient(t, 200)
+	defer ts.Close()
+	d := NewDatadog(client, "hoge")
+
+	if err := d.Post(context.Background(), testMetrics()); err != nil {
+		t.Error(err)
+	}
+
+	series := ts.series()
+	if len(series) != 1 {
+		t.Fatalf("wrong result : %d series are posted", len(series))
+	}
+	s := series[0]
+	expected := []interface{}{
+		"aws.ri.utilization", "gauge", "hoge", "percent",
+		[]string{"account:hoge", "hoge", "service:Amazon Redshift"},
+		float64(1583020800), float64(80),
+	}
+	actual := []interface{}{
+		*s.Metric, *s.Type, *s.Host, *s.Unit,
+		s.Tags,
+		*s.Points[0][0], *s.Points[0][1],
+	}
+	if diff := cmp.Diff(expected, actual); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestDatadogPostNothing(t *testing.T) {
+	client, ts := newDatadogClient(t, 200)
+	defer ts.Close()
+	d := NewDatadog(client, "hoge")
+
+	if err := d.Post(context.Background(), []Metric{}); err != nil {
+		t.Error(err)
+	}
+	if len(ts.requests) != 0 {
+		t.Errorf("wrong result : %d requests are sent", len(ts.requests))
+	}
+}
+
+func TestDatadogPostFailed(t *testing.T) {
+	client, ts := newDatadogClient(t, 403)
+	defer ts.Close()
+	d := NewDatadog(client, "hoge")
+	d.newBackOff = noBackOff(3)
+
+	if err := d.Post(context.Background(), testMetrics()); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+	// 4xx はリトライしない
+	if len(ts.requests) != 1 {
+		t.Errorf("wrong result : %d requests are sent", len(ts.requests))
+	}
+}
+
+func TestIsDatadogAuthError(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected bool
+	}{
+		{err: errors.New("API error 403 Forbidden: {\"errors\": [\"Forbidden\"]}"), expected: true},
+		{err: errors.New("API error 401 Unauthorized: {}"), expected: true},
+		{err: errors.New("API error 429 Too Many Requests: {}"), expected: false},
+		{err: errors.New("connection refused"), expected: false},
+		{err: &BatchErrors{Errs: []error{errors.New("API error 500 Internal Server Error: {}"), errors.New("API error 403 Forbidden: {}")}, Batches: 3}, expected: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			if actual := IsDatadogAuthError(tt.err); actual != tt.expected {
+				t.Errorf("wrong result : %v", actual)
+			}
+		})
+	}
+}
+
+func TestDatadogPostInBatches(t *testing.T) {
+	client, ts := newDatadogClient(t, 202)
+	defer ts.Close()
+	d := NewDatadog(client, "hoge")
+
+	metrics := []Metric{}
+	for i := 0; i < 10; i++ {
+		metrics = append(metrics, testMetrics()...)
+	}
+	one, err := json.Marshal(struct {
+		Series []datadog.Metric `json:"series"`
+	}{Series: d.series(metrics[:3])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 3 series 分のペイロードに収まるように分割する
+	d.maxPayloadBytes = len(one)
+
+	if err := d.Post(context.Background(), metrics); err != nil {
+		t.Fatal(err)
+	}
+	sizes := []int{}
+	for _, r := range ts.requests {
+		sizes = append(sizes, len(r))
+	}
+	if diff := cmp.Diff([]int{3, 3, 3, 1}, sizes); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 失敗したバッチがあっても残りのバッチを送信し、失敗をまとめて返す
+func TestDatadogPostBatchFailed(t *testing.T) {
+	client, ts := newDatadogClient(t, 202, 400, 202, 400)
+	defer ts.Close()
+	d := NewDatadog(client, "hoge")
+	d.newBackOff = noBackOff(3)
+
+	metrics := []Metric{}
+	for i := 0; i < 4; i++ {
+		metrics = append(metrics, testMetrics()[0])
+	}
+	one, err := json.Marshal(struct {
+		Series []datadog.Metric `json:"series"`
+	}{Series: d.series(metrics[:1])})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 1 series ずつ送信する
+	d.maxPayloadBytes = len(one)
+
+	err = d.Post(context.Background(), metrics)
+	batchErrs, ok := err.(*BatchErrors)
+	if !ok {
+		t.Fatalf("wrong result : %v", err)
+	}
+	if len(batchErrs.Errs) != 2 || batchErrs.Batches != 4 {
+		t.Errorf("wrong result : %s", err)
+	}
+	if len(ts.requests) != 4 {
+		t.Errorf("wrong result : %d requests are sent", len(ts.requests))
+	}
+}
+
+func TestDatadogPostRetried(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		requests int
+		wantErr  bool
+	}{
+		{
+			name:     "rate limited",
+			statuses: []int{429, 202},
+			requests: 2,
+		},
+		{
+			name:     "server error",
+			statuses: []int{500, 503, 202},
+			requests: 3,
+		},
+		{
+			// リトライ回数を超えたらエラーを返す
+			name:     "server error persists",
+			statuses: []int{500},
+			requests: 4,
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, ts := newDatadogClient(t, tt.statuses...)
+			defer ts.Close()
+			d := NewDatadog(client, "hoge")
+			d.newBackOff = noBackOff(3)
+
+			if err := d.Post(context.Background(), testMetrics()); (err != nil) != tt.wantErr {
+				t.Fatalf("Post() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if diff := cmp.Diff(tt.requests, len(ts.requests)); diff != "" {
+				t.Errorf("wrong result : %s", diff)
+			}
+		})
+	}
+}
+
+// 次のリトライまでに ctx の期限が来るならリトライせずに諦める
+func TestDatadogPostRetryDeadline(t *testing.T) {
+	client, ts := newDatadogClient(t, 500)
+	defer ts.Close()
+	d := NewDatadog(client, "hoge")
+	d.newBackOff = func() backoff.BackOff {
+		return backoff.NewConstantBackOff(time.Minute)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	started := time.Now()
+	if err := d.Post(ctx, testMetrics()); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("wrong result : %s elapsed", elapsed)
+	}
+	if len(ts.requests) != 1 {
+		t.Errorf("wrong result : %d requests are sent", len(ts.requests))
+	}
+}
@@ -0,0 +1,124 @@
+package sink
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Units of metrics
+const (
+	UnitNone    = ""
+	UnitPercent = "percent"
+	UnitDollar  = "dollar"
+	UnitHour    = "hour"
+	UnitDay     = "day"
+)
+
+// Metric : a data point independent of the backend
+type Metric struct {
+	Name      string
+	Value     float64
+	Timestamp time.Time
+	// Tags : "key:value" or a bare value
+	Tags []string
+	Unit string
+}
+
+// Sink : backend which metrics are posted to
+type Sink interface {
+	// Name ... name of the backend used in error reports
+	Name() string
+	// Post ... post metrics to the backend, giving up the requests and retries when ctx is done
+	Post(ctx context.Context, metrics []Metric) error
+}
+
+// PostError : failure of a sink
+type PostError struct {
+	Sink string
+	Err  error
+}
+
+func (e *PostError) Error() string {
+	return e.Sink + ": " + e.Err.Error()
+}
+
+// Errors : failures of sinks posting the same metrics
+type Errors []*PostError
+
+func (e Errors) Error() string {
+	msgs := make([]string, 0, len(e))
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return "failed to post metrics to " + strings.Join(msgs, ", ")
+}
+
+// Multi : fan out metrics to several sinks
+type Multi struct {
+	sinks []Sink
+}
+
+// NewMulti ... generate a sink posting metrics to every given sink
+func NewMulti(sinks ...Sink) *Multi {
+	return &Multi{
+		sinks: sinks,
+	}
+}
+
+// Name ... names of the sinks
+func (m *Multi) Name() string {
+	names := make([]string, 0, len(m.sinks))
+	for _, s := range m.sinks {
+		names = append(names, s.Name())
+	}
+	return strings.Join(names, ",")
+}
+
+// Post ... post metrics to every sink, even if some of them fail
+//
+// The returned error is Errors which reports the failed sinks.
+func (m *Multi) Post(ctx context.Context, metrics []Metric) error {
+	var errs Errors
+	for _, s := range m.sinks {
+		if err := s.Post(ctx, metrics); err != nil {
+			errs = append(errs, &PostError{Sink: s.Name(), Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// Dedupe ... keep only the last data point of each series at each timestamp
+//
+// A series is identified by the name and the tags regardless of their order.
+func Dedupe(metrics []Metric) []Metric {
+	index := map[string]int{}
+	deduped := make([]Metric, 0, len(metrics))
+	for _, m := range metrics {
+		tags := append([]string{}, m.Tags...)
+		sort.Strings(tags)
+		key := strings.Join([]string{m.Name, strings.Join(tags, ","), strconv.FormatInt(m.Timestamp.Unix(), 10)}, "|")
+
+		if i, ok := index[key]; ok {
+			deduped[i] = m
+			continue
+		}
+		index[key] = len(deduped)
+		deduped = append(deduped, m)
+	}
+	return deduped
+}
+
+// splitTag ... split a tag into the key and the value, the key is empty for a bare value
+func splitTag(tag string) (key, value string) {
+	i := strings.Index(tag, ":")
+	if i < 0 {
+		return "", tag
+	}
+	return tag[:i], tag[i+1:]
+}
@@ -0,0 +1,125 @@
+package sink
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+type mockSink struct {
+	name    string
+	metrics []Metric
+	Error   error
+}
+
+func (m *mockSink) Name() string {
+	return m.name
+}
+
+func (m *mockSink) Post(ctx context.Context, metrics []Metric) error {
+	m.metrics = append(m.metrics, metrics...)
+	return m.Error
+}
+
+func testMetrics() []Metric {
+	return []Metric{
+		{
+			Name:      "aws.ri.utilization",
+			Value:     80,
+			Timestamp: time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC),
+			Tags:      []string{"account:hoge", "hoge", "service:Amazon Redshift"},
+			Unit:      UnitPercent,
+		},
+	}
+}
+
+func TestMultiPost(t *testing.T) {
+	a := &mockSink{name: "a"}
+	b := &mockSink{name: "b"}
+	m := NewMulti(a, b)
+
+	if diff := cmp.Diff("a,b", m.Name()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if err := m.Post(context.Background(), testMetrics()); err != nil {
+		t.Error(err)
+	}
+	for _, s := range []*mockSink{a, b} {
+		if diff := cmp.Diff(testMetrics(), s.metrics); diff != "" {
+			t.Errorf("sink: %s wrong result : %s", s.name, diff)
+		}
+	}
+}
+
+// 1 つの sink が失敗しても残りの sink へは送信し、失敗した sink を報告する
+func TestMultiPostPartiallyFailed(t *testing.T) {
+	a := &mockSink{name: "a", Error: errors.New("error occured")}
+	b := &mockSink{name: "b"}
+	c := &mockSink{name: "c", Error: errors.New("error occured")}
+	m := NewMulti(a, b, c)
+
+	err := m.Post(context.Background(), testMetrics())
+	if err == nil {
+		t.Fatal("wrong result : err is nil")
+	}
+
+	var errs Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("wrong error type : %T", err)
+	}
+	failed := []string{}
+	for _, e := range errs {
+		failed = append(failed, e.Sink)
+	}
+	if diff := cmp.Diff([]string{"a", "c"}, failed); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if diff := cmp.Diff(testMetrics(), b.metrics); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+	if diff := cmp.Diff("failed to post metrics to a: error occured, c: error occured", err.Error()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 同じ系列の同じ時刻のデータポイントは最後のものだけを残す
+func TestDedupe(t *testing.T) {
+	day1 := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	day2 := time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC)
+	metrics := []Metric{
+		{Name: "aws.ri.coverage", Value: 10, Timestamp: day1, Tags: []string{"service:a", "region:x"}},
+		{Name: "aws.ri.coverage", Value: 20, Timestamp: day2, Tags: []string{"service:a", "region:x"}},
+		{Name: "aws.ri.coverage", Value: 30, Timestamp: day1, Tags: []string{"service:b", "region:x"}},
+		{Name: "aws.ri.utilization", Value: 40, Timestamp: day1, Tags: []string{"service:a", "region:x"}},
+		{Name: "aws.ri.coverage", Value: 50, Timestamp: day1, Tags: []string{"region:x", "service:a"}},
+	}
+
+	expected := []Metric{
+		{Name: "aws.ri.coverage", Value: 50, Timestamp: day1, Tags: []string{"region:x", "service:a"}},
+		{Name: "aws.ri.coverage", Value: 20, Timestamp: day2, Tags: []string{"service:a", "region:x"}},
+		{Name: "aws.ri.coverage", Value: 30, Timestamp: day1, Tags: []string{"service:b", "region:x"}},
+		{Name: "aws.ri.utilization", Value: 40, Timestamp: day1, Tags: []string{"service:a", "region:x"}},
+	}
+	if diff := cmp.Diff(expected, Dedupe(metrics)); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestSplitTag(t *testing.T) {
+	tests := []struct {
+		tag, key, value string
+	}{
+		{"service:Amazon Redshift", "service", "Amazon Redshift"},
+		{"url:https://example.com", "url", "https://example.com"},
+		{"hoge", "", "hoge"},
+	}
+	for _, tt := range tests {
+		k, v := splitTag(tt.tag)
+		if diff := cmp.Diff([]string{tt.key, tt.value}, []string{k, v}); diff != "" {
+			t.Errorf("tag: %s wrong result : %s", tt.tag, diff)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Formats of the metrics written by Writer
+const (
+	FormatTable = "table"
+	FormatJSON  = "json"
+)
+
+// Writer : write metrics to a writer instead of posting them, e.g. to review them in a dry run
+type Writer struct {
+	w      io.Writer
+	format string
+}
+
+// writtenMetric : a metric written as a line of JSON
+type writtenMetric struct {
+	Metric    string   `json:"metric"`
+	Timestamp string   `json:"timestamp"`
+	Value     float64  `json:"value"`
+	Unit      string   `json:"unit,omitempty"`
+	Tags      []string `json:"tags"`
+}
+
+// NewWriter ... generate a sink writing metrics to w as a table or JSON lines
+func NewWriter(w io.Writer, format string) (*Writer, error) {
+	switch format {
+	case FormatTable, FormatJSON:
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+	return &Writer{
+		w:      w,
+		format: format,
+	}, nil
+}
+
+// Name ... writer
+func (w *Writer) Name() string {
+	return "writer"
+}
+
+// Post ... write the metrics in the order given, timestamps in RFC 3339 of UTC
+func (w *Writer) Post(ctx context.Context, metrics []Metric) error {
+	if w.format == FormatJSON {
+		return w.writeJSON(metrics)
+	}
+	return w.writeTable(metrics)
+}
+
+// writeTable ... write the metrics as a table whose columns are aligned
+func (w *Writer) writeTable(metrics []Metric) error {
+	tw := tabwriter.NewWriter(w.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "METRIC\tTIMESTAMP\tVALUE\tUNIT\tTAGS")
+	for _, m := range metrics {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n",
+			m.Name,
+			m.Timestamp.UTC().Format(time.RFC3339),
+			strconv.FormatFloat(m.Value, 'f', -1, 64),
+			m.Unit,
+			strings.Join(m.Tags, ","),
+		)
+	}
+	return tw.Flush()
+}
+
+// writeJSON ... write a metric as a JSON object in each line, so that each of them is a log event of CloudWatch Logs
+func (w *Writer) writeJSON(metrics []Metric) error {
+	encoder := json.NewEncoder(w.w)
+	for _, m := range metrics {
+		tags := m.Tags
+		if tags == nil {
+			tags = []string{}
+		}
+		if err := encoder.Encode(writtenMetric{
+			Metric:    m.Name,
+			Timestamp: m.Timestamp.UTC().Format(time.RFC3339),
+			Value:     m.Value,
+			Unit:      m.Unit,
+			Tags:      tags,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package sink
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func writtenMetrics() []Metric {
+	return append(testMetrics(), Metric{
+		Name:      "ri_plotter.run.errors",
+		Value:     0,
+		Timestamp: time.Date(2020, 3, 3, 19, 0, 0, 0, time.FixedZone("JST", 9*60*60)),
+		Tags:      []string{"account:hoge", "hoge"},
+	})
+}
+
+func TestWriterPostTable(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, FormatTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Post(context.Background(), writtenMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `METRIC                 TIMESTAMP             VALUE  UNIT     TAGS
+aws.ri.utilization     2020-03-01T00:00:00Z  80     percent  account:hoge,hoge,service:Amazon Redshift
+ri_plotter.run.errors  2020-03-03T10:00:00Z  0               account:hoge,hoge
+`
+	if diff := cmp.Diff(expected, b.String()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+// 1 行に 1 つのメトリクスを JSON で書き出す
+func TestWriterPostJSON(t *testing.T) {
+	var b bytes.Buffer
+	w, err := NewWriter(&b, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Post(context.Background(), writtenMetrics()); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"metric":"aws.ri.utilization","timestamp":"2020-03-01T00:00:00Z","value":80,"unit":"percent","tags":["account:hoge","hoge","service:Amazon Redshift"]}
+{"metric":"ri_plotter.run.errors","timestamp":"2020-03-03T10:00:00Z","value":0,"tags":["account:hoge","hoge"]}
+`
+	if diff := cmp.Diff(expected, b.String()); diff != "" {
+		t.Errorf("wrong result : %s", diff)
+	}
+}
+
+func TestNewWriterFailed(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, "yaml"); err == nil {
+		t.Error("wrong result : err is nil")
+	}
+}
+
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func TestWriterPostFailed(t *testing.T) {
+	for _, format := range []string{FormatTable, FormatJSON} {
+		w, err := NewWriter(failingWriter{}, format)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Post(context.Background(), writtenMetrics()); err == nil {
+			t.Errorf("format: %s wrong result : err is nil", format)
+		}
+	}
+}